@@ -1,17 +1,51 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"hash"
+	"hash/fnv"
 	"io"
+	"io/fs"
+	"math"
+	"math/bits"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
+	"text/template"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/netutil"
+	"gopkg.in/natefinch/lumberjack.v2"
 	"gopkg.in/yaml.v3"
 )
 
@@ -22,390 +56,7009 @@ type WebhookConfig struct {
 	Timeout       int               `json:"timeout" yaml:"timeout"` // in milliseconds
 	Headers       map[string]string `json:"headers" yaml:"headers"`
 	EnableLogging bool              `json:"enable_logging" yaml:"enable_logging"`
+	// Slow-consumer simulation: write the body in chunks with a delay between flushes
+	WriteChunkSize     int `json:"write_chunk_size,omitempty" yaml:"write_chunk_size,omitempty"`           // in bytes, defaults to 1
+	WriteDelayPerChunk int `json:"write_delay_per_chunk,omitempty" yaml:"write_delay_per_chunk,omitempty"` // in milliseconds
+	// JSON Schema that request bodies must satisfy (inline JSON or a file path)
+	RequestSchema     string `json:"request_schema,omitempty" yaml:"request_schema,omitempty"`
+	RequestSchemaFile string `json:"request_schema_file,omitempty" yaml:"request_schema_file,omitempty"`
+	// Fraction (0.0-1.0) of requests whose details are logged when EnableLogging is on.
+	// 0 means every request. Metrics always count every request regardless of sampling.
+	LogSampleRate float64 `json:"log_sample_rate,omitempty" yaml:"log_sample_rate,omitempty"`
+	// Per-client-IP escalating delay for testing clients that should back off
+	Backoff *BackoffConfig `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	// Random delay distribution; replaces the fixed Timeout when set
+	DelayDistribution *DelayDistribution `json:"delay_distribution,omitempty" yaml:"delay_distribution,omitempty"`
+	// Keep the connection open this long after the body is written (in milliseconds)
+	LingerMs int `json:"linger_ms,omitempty" yaml:"linger_ms,omitempty"`
+	// Append every raw request body to this file for later replay, rotating at CaptureMaxSizeMB
+	CaptureBodiesTo  string `json:"capture_bodies_to,omitempty" yaml:"capture_bodies_to,omitempty"`
+	CaptureMaxSizeMB int    `json:"capture_max_size_mb,omitempty" yaml:"capture_max_size_mb,omitempty"` // defaults to 10
+	// Serve the body from this file (read per request) instead of ResponseBody
+	ResponseBodyFile string `json:"response_body_file,omitempty" yaml:"response_body_file,omitempty"`
+	// Render the body as a Go text/template with the request available as data
+	ResponseTemplate bool `json:"response_template,omitempty" yaml:"response_template,omitempty"`
+	// Indent JSON response bodies before sending (logs keep the compact form)
+	PrettyJSON bool `json:"pretty_json,omitempty" yaml:"pretty_json,omitempty"`
+	// Server-enforced deadline for the whole handler, including the artificial delay (in milliseconds)
+	HandlerTimeout int `json:"handler_timeout,omitempty" yaml:"handler_timeout,omitempty"`
+	// Process at most MaxConcurrency requests at once; up to MaxQueue more wait
+	// for a slot (0 = unbounded) and the rest get 503
+	MaxConcurrency int `json:"max_concurrency,omitempty" yaml:"max_concurrency,omitempty"`
+	MaxQueue       int `json:"max_queue,omitempty" yaml:"max_queue,omitempty"`
+	// Emit an ETag (hash of the body) and answer matching If-None-Match with 304
+	ETag         bool   `json:"etag,omitempty" yaml:"etag,omitempty"`
+	CacheControl string `json:"cache_control,omitempty" yaml:"cache_control,omitempty"` // Cache-Control response header
+	// Let clients pick their delay with an X-Delay-Ms header, overriding Timeout,
+	// capped at MaxClientDelayMs (default 10000)
+	AllowClientDelay bool `json:"allow_client_delay,omitempty" yaml:"allow_client_delay,omitempty"`
+	MaxClientDelayMs int  `json:"max_client_delay_ms,omitempty" yaml:"max_client_delay_ms,omitempty"`
+	// Per-HTTP-method delay in milliseconds, overriding Timeout for those methods
+	MethodTimeouts map[string]int `json:"method_timeouts,omitempty" yaml:"method_timeouts,omitempty"`
+	// Proxy requests to this upstream URL and relay its response instead of the configured one
+	ForwardTo      string `json:"forward_to,omitempty" yaml:"forward_to,omitempty"`
+	ForwardTimeout int    `json:"forward_timeout,omitempty" yaml:"forward_timeout,omitempty"` // in milliseconds, defaults to 30000
+	// Control which request headers are logged: only the allowlist (when set), minus the denylist.
+	// Redacted headers are logged as "***" (defaults to defaultRedactedHeaders) and the
+	// logged headers are capped at LogHeaderMaxBytes (defaults to 8192)
+	LogHeaderAllowlist []string `json:"log_header_allowlist,omitempty" yaml:"log_header_allowlist,omitempty"`
+	LogHeaderDenylist  []string `json:"log_header_denylist,omitempty" yaml:"log_header_denylist,omitempty"`
+	LogHeaderRedact    []string `json:"log_header_redact,omitempty" yaml:"log_header_redact,omitempty"`
+	LogHeaderMaxBytes  int      `json:"log_header_max_bytes,omitempty" yaml:"log_header_max_bytes,omitempty"`
+	// Require a valid HMAC signature of the raw body; mismatches get 401
+	SignatureVerification *SignatureVerification `json:"signature_verification,omitempty" yaml:"signature_verification,omitempty"`
+	// Load-sensitive latency: add this many milliseconds per other request in flight
+	ConcurrencyLatencyFactor float64 `json:"concurrency_latency_factor,omitempty" yaml:"concurrency_latency_factor,omitempty"`
+	// Fail requests once the same body arrives more than Count times in a row
+	FailOnRepeat *FailOnRepeatConfig `json:"fail_on_repeat,omitempty" yaml:"fail_on_repeat,omitempty"`
+	// Don't keep per-client-IP request counts (GET /api/webhooks/:id/clients)
+	DisableClientTracking bool `json:"disable_client_tracking,omitempty" yaml:"disable_client_tracking,omitempty"`
+	// Delay rules evaluated against the request number, first match wins, e.g.
+	// ["every:10 => 1000ms", "sequence: 0ms, 50ms, 200ms"]
+	DelayPattern []string `json:"delay_pattern,omitempty" yaml:"delay_pattern,omitempty"`
+	// Extra delay proportional to the request body, in milliseconds per KB,
+	// capped at MaxBodyDelayMs (default 10000)
+	DelayPerKB     int `json:"delay_per_kb,omitempty" yaml:"delay_per_kb,omitempty"`
+	MaxBodyDelayMs int `json:"max_body_delay_ms,omitempty" yaml:"max_body_delay_ms,omitempty"`
+	// Serverless-style cold start: the first request after IdleMs without traffic waits DelayMs
+	ColdStart *ColdStartConfig `json:"cold_start,omitempty" yaml:"cold_start,omitempty"`
+	// Answer successive requests with these steps in turn, starting over after the last
+	Sequence []ResponseStep `json:"sequence,omitempty" yaml:"sequence,omitempty"`
+	// Seed for delay_distribution and log_sample_rate draws, making them repeatable; 0 seeds from the clock
+	RandomSeed int64 `json:"random_seed,omitempty" yaml:"random_seed,omitempty"`
+	// Headers that must be present (any value, names case-insensitive); requests without them are rejected
+	RequiredHeaders       []string `json:"required_headers,omitempty" yaml:"required_headers,omitempty"`
+	RequiredHeadersStatus int      `json:"required_headers_status,omitempty" yaml:"required_headers_status,omitempty"` // defaults to 400
+	// Send a Server-Timing header splitting the response time into the artificial delay and handler overhead
+	ServerTiming bool `json:"server_timing,omitempty" yaml:"server_timing,omitempty"`
+	// Also count each request on these webhooks (by ID), simulating a broadcast; the response is still this webhook's
+	FanOutTo []string `json:"fan_out_to,omitempty" yaml:"fan_out_to,omitempty"`
+	// Latency floor: wait before responding until the request has taken at least this long; never adds to slower requests
+	MinLatencyMs int `json:"min_latency_ms,omitempty" yaml:"min_latency_ms,omitempty"`
+	// HMAC-sign the response body into a header (default X-Response-Signature), mirroring signature_verification
+	ResponseSigning *SignatureVerification `json:"response_signing,omitempty" yaml:"response_signing,omitempty"`
+	// Log only these fields of JSON request bodies, e.g. "order.id" or "items[0].sku", instead of the whole body
+	LogBodyJSONPaths []string `json:"log_body_json_paths,omitempty" yaml:"log_body_json_paths,omitempty"`
+	// Client deadline to measure responses against: metrics report how much of it requests used
+	DeadlineBudgetMs int `json:"deadline_budget_ms,omitempty" yaml:"deadline_budget_ms,omitempty"`
+	// Fixture lookup: the value of query parameter QueryResponseParam picks the body from QueryResponseMap
+	QueryResponseParam string            `json:"query_response_param,omitempty" yaml:"query_response_param,omitempty"`
+	QueryResponseMap   map[string]string `json:"query_response_map,omitempty" yaml:"query_response_map,omitempty"`
+	// Answer 404 for values missing from QueryResponseMap instead of the default body
+	QueryResponseNotFound bool `json:"query_response_not_found,omitempty" yaml:"query_response_not_found,omitempty"`
+	// Round-robin through these bodies instead of ResponseBody; entries are strings or {body, status_code, content_type}
+	ResponseBodyList []ResponseBodyEntry `json:"response_body_list,omitempty" yaml:"response_body_list,omitempty"`
+	// Send the body with chunked transfer encoding (no Content-Length) at full speed
+	ForceChunked bool `json:"force_chunked,omitempty" yaml:"force_chunked,omitempty"`
+	// Answer 503 for this many milliseconds after the webhook is created (or the server starts)
+	StartupDelayMs int `json:"startup_delay_ms,omitempty" yaml:"startup_delay_ms,omitempty"`
+	// Count requests per value of this JSON body field, reported as label_counts
+	MetricsLabelJSONPath  string `json:"metrics_label_json_path,omitempty" yaml:"metrics_label_json_path,omitempty"`
+	MetricsLabelMaxValues int    `json:"metrics_label_max_values,omitempty" yaml:"metrics_label_max_values,omitempty"` // defaults to 100
+	// Answer 503 to the first N requests after a metrics reset, then respond normally
+	FailFirstN int `json:"fail_first_n,omitempty" yaml:"fail_first_n,omitempty"`
+	// Record lifecycle timestamps (received, delay start/end, response written) per request
+	TraceRequests bool `json:"trace_requests,omitempty" yaml:"trace_requests,omitempty"`
+	// Open the circuit (fast 503s) after consecutive 5xx responses, then probe with half-open trials
+	CircuitBreaker *CircuitBreakerConfig `json:"circuit_breaker,omitempty" yaml:"circuit_breaker,omitempty"`
+	// Answer 414 when the request URI (path and query) is longer than this; 0 means no limit
+	MaxURILength int `json:"max_uri_length,omitempty" yaml:"max_uri_length,omitempty"`
+	// Weighted A/B variants, sticky per client IP or cookie
+	ABSplit *ABSplitConfig `json:"ab_split,omitempty" yaml:"ab_split,omitempty"`
 }
 
-type Webhook struct {
-	ID          string         `json:"id" yaml:"id"`
-	Name        string         `json:"name" yaml:"name"`
-	Path        string         `json:"path" yaml:"path"`
-	Config      WebhookConfig  `json:"config" yaml:"config"`
-	Calculator  *TPSCalculator `json:"-" yaml:"-"`
-	CreatedAt   time.Time      `json:"created_at" yaml:"created_at"`
-	LastRequest *time.Time     `json:"last_request,omitempty" yaml:"last_request,omitempty"`
+// ResponseBodyEntry is one body of a ResponseBodyList. Zero StatusCode and
+// empty ContentType fall back to the webhook's.
+type ResponseBodyEntry struct {
+	Body        string `json:"body" yaml:"body"`
+	StatusCode  int    `json:"status_code,omitempty" yaml:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty" yaml:"content_type,omitempty"`
 }
 
-type WebhookConfigFile struct {
-	Server struct {
-		Port int    `yaml:"port"`
-		Host string `yaml:"host"`
-	} `yaml:"server"`
-	Logging struct {
-		LogFile   string `yaml:"log_file"`
-		LogLevel  string `yaml:"log_level"`
-		LogFormat string `yaml:"log_format"`
-	} `yaml:"logging"`
-	DefaultWebhooks []struct {
-		ID     string        `yaml:"id"`
-		Name   string        `yaml:"name"`
-		Path   string        `yaml:"path"`
-		Config WebhookConfig `yaml:"config"`
-	} `yaml:"default_webhooks"`
+// UnmarshalJSON accepts a plain string as shorthand for an entry with just a body
+func (e *ResponseBodyEntry) UnmarshalJSON(data []byte) error {
+	var body string
+	if err := json.Unmarshal(data, &body); err == nil {
+		*e = ResponseBodyEntry{Body: body}
+		return nil
+	}
+	type entry ResponseBodyEntry
+	return json.Unmarshal(data, (*entry)(e))
 }
 
-type TPSCalculator struct {
-	mu           sync.RWMutex
-	requestCount int64
-	startTime    time.Time
-	lastTime     time.Time
-	isActive     bool
+// UnmarshalYAML accepts a plain string as shorthand for an entry with just a body
+func (e *ResponseBodyEntry) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*e = ResponseBodyEntry{Body: value.Value}
+		return nil
+	}
+	type entry ResponseBodyEntry
+	return value.Decode((*entry)(e))
 }
 
-type WebhookServer struct {
-	webhooks map[string]*Webhook
-	mu       sync.RWMutex
-	router   *gin.Engine
+// bodyRotation hands out response_body_list indexes in turn, safe for concurrent requests
+type bodyRotation struct {
+	counter atomic.Int64
 }
 
-func NewTPSCalculator() *TPSCalculator {
-	return &TPSCalculator{}
+// next returns the index of the entry for the next request in a list of n entries
+func (r *bodyRotation) next(n int) int {
+	return int((r.counter.Add(1) - 1) % int64(n))
 }
 
-func loadConfigFromYAML(filename string) (*WebhookConfigFile, error) {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, err
+// peek returns the index next would return, without advancing the rotation
+func (r *bodyRotation) peek(n int) int {
+	return int(r.counter.Load() % int64(n))
+}
+
+// ABSplitConfig serves weighted response variants, the same one to each client
+type ABSplitConfig struct {
+	Variants []ABVariant `json:"variants" yaml:"variants"`
+	// "ip" (default) hashes the client IP; "cookie" hashes the value of
+	// CookieName, assigning a new random value to clients without one
+	StickyBy   string `json:"sticky_by,omitempty" yaml:"sticky_by,omitempty"`
+	CookieName string `json:"cookie_name,omitempty" yaml:"cookie_name,omitempty"` // defaults to ab_client
+	Header     string `json:"header,omitempty" yaml:"header,omitempty"`           // names the variant, defaults to X-AB-Variant
+}
+
+// ABVariant is one arm of an A/B split. Zero StatusCode and empty ContentType
+// fall back to the webhook's.
+type ABVariant struct {
+	Name         string `json:"name" yaml:"name"`
+	Weight       int    `json:"weight" yaml:"weight"` // relative share of clients
+	ResponseBody string `json:"response_body" yaml:"response_body"`
+	StatusCode   int    `json:"status_code,omitempty" yaml:"status_code,omitempty"`
+	ContentType  string `json:"content_type,omitempty" yaml:"content_type,omitempty"`
+}
+
+// abSplit is a validated ABSplitConfig with defaults applied
+type abSplit struct {
+	variants []ABVariant
+	bounds   []uint64 // cumulative weights, parallel to variants
+	cookie   string   // empty when sticky by IP
+	header   string
+}
+
+// abCookieMaxAge keeps the sticky cookie for a year
+const abCookieMaxAge = 365 * 24 * 60 * 60
+
+// newABSplit validates the config and applies defaults.
+// It returns nil when ab_split is not configured.
+func newABSplit(config *ABSplitConfig) (*abSplit, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if len(config.Variants) < 2 {
+		return nil, fmt.Errorf("ab_split needs at least 2 variants, got %d", len(config.Variants))
+	}
+	split := &abSplit{variants: config.Variants, header: config.Header}
+	names := make(map[string]bool, len(config.Variants))
+	var total uint64
+	for i, variant := range config.Variants {
+		if variant.Name == "" || names[variant.Name] {
+			return nil, fmt.Errorf("ab_split variants[%d] needs a unique name", i)
+		}
+		names[variant.Name] = true
+		if variant.Weight < 0 {
+			return nil, fmt.Errorf("ab_split variant %s weight must not be negative, got %d", variant.Name, variant.Weight)
+		}
+		if variant.StatusCode != 0 && (variant.StatusCode < 100 || variant.StatusCode > 599) {
+			return nil, fmt.Errorf("ab_split variant %s status_code %d is not a valid HTTP status", variant.Name, variant.StatusCode)
+		}
+		total += uint64(variant.Weight)
+		split.bounds = append(split.bounds, total)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("ab_split needs at least one variant with a positive weight")
 	}
+	switch config.StickyBy {
+	case "", "ip":
+	case "cookie":
+		split.cookie = config.CookieName
+		if split.cookie == "" {
+			split.cookie = "ab_client"
+		}
+	default:
+		return nil, fmt.Errorf("ab_split sticky_by must be ip or cookie, got %q", config.StickyBy)
+	}
+	if split.header == "" {
+		split.header = "X-AB-Variant"
+	}
+	return split, nil
+}
 
-	var config WebhookConfigFile
-	err = yaml.Unmarshal(data, &config)
-	if err != nil {
-		return nil, err
+// pick returns the client's variant. Cookie-sticky clients without the cookie
+// are given a new random key, set as a cookie on the response.
+func (s *abSplit) pick(c *gin.Context) ABVariant {
+	key := c.ClientIP()
+	if s.cookie != "" {
+		if value, err := c.Cookie(s.cookie); err == nil && value != "" {
+			key = value
+		} else {
+			key = uuid.New().String()
+			http.SetCookie(c.Writer, s.stickyCookie(key))
+		}
 	}
+	return s.variantFor(key)
+}
 
-	return &config, nil
+// stickyCookie is the cookie that keeps a client on the variant of key
+func (s *abSplit) stickyCookie(key string) *http.Cookie {
+	return &http.Cookie{
+		Name:     s.cookie,
+		Value:    key,
+		Path:     "/",
+		MaxAge:   abCookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
 }
 
-func NewWebhookServer(router *gin.Engine) (*WebhookServer, *WebhookConfigFile) {
-	server := &WebhookServer{
-		webhooks: make(map[string]*Webhook),
-		router:   router,
+// variantFor returns the variant a client key is assigned to
+func (s *abSplit) variantFor(key string) ABVariant {
+	point := hashKey(key) % s.bounds[len(s.bounds)-1]
+	for i, bound := range s.bounds {
+		if point < bound {
+			return s.variants[i]
+		}
 	}
+	return s.variants[len(s.variants)-1]
+}
 
-	// Try to load from config.yaml first
-	config, err := loadConfigFromYAML("config.yaml")
-	if err != nil {
-		logrus.Warnf("Could not load config.yaml: %v, using default configuration", err)
-		// Use default configuration if YAML file not found
-		server.loadDefaultWebhooks()
-		// Return default config
-		defaultConfig := &WebhookConfigFile{
-			Server: struct {
-				Port int    `yaml:"port"`
-				Host string `yaml:"host"`
-			}{
-				Port: 8080,
-				Host: "localhost",
-			},
+// webhookRand is a webhook's own random source. *rand.Rand is not safe for
+// concurrent use, so draws are serialized. A nil webhookRand uses the global source.
+type webhookRand struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// newWebhookRand seeds a random source, from the clock when seed is 0
+func newWebhookRand(seed int64) *webhookRand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &webhookRand{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (r *webhookRand) Float64() float64 {
+	if r == nil {
+		return rand.Float64()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Float64()
+}
+
+func (r *webhookRand) NormFloat64() float64 {
+	if r == nil {
+		return rand.NormFloat64()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.NormFloat64()
+}
+
+func (r *webhookRand) ExpFloat64() float64 {
+	if r == nil {
+		return rand.ExpFloat64()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.ExpFloat64()
+}
+
+// ResponseStep is one response of a Sequence. Zero fields fall back to the
+// webhook's own status code, body and delay; headers are added to the webhook's.
+type ResponseStep struct {
+	StatusCode   int               `json:"status_code,omitempty" yaml:"status_code,omitempty"`
+	ResponseBody string            `json:"response_body,omitempty" yaml:"response_body,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	DelayMs      *int              `json:"delay_ms,omitempty" yaml:"delay_ms,omitempty"` // replaces the normal delay when set
+}
+
+// responseSequence hands out sequence steps in order, safe for concurrent requests
+type responseSequence struct {
+	steps   []ResponseStep
+	counter atomic.Int64 // requests served since the sequence was last changed
+}
+
+// newResponseSequence validates the steps. It returns nil when no sequence is configured.
+func newResponseSequence(steps []ResponseStep) (*responseSequence, error) {
+	if len(steps) == 0 {
+		return nil, nil
+	}
+	for i, step := range steps {
+		if step.StatusCode != 0 && (step.StatusCode < 100 || step.StatusCode > 599) {
+			return nil, fmt.Errorf("sequence[%d] status_code %d is not a valid HTTP status", i, step.StatusCode)
 		}
-		return server, defaultConfig
-	} else {
-		logrus.Info("Loading webhooks from config.yaml")
-		server.loadWebhooksFromConfig(config)
-		// Set defaults if not specified
-		if config.Server.Port == 0 {
-			config.Server.Port = 8080
+		if step.DelayMs != nil && *step.DelayMs < 0 {
+			return nil, fmt.Errorf("sequence[%d] delay_ms must not be negative", i)
+		}
+	}
+	return &responseSequence{steps: steps}, nil
+}
+
+// next returns the step for the next request and its index
+func (s *responseSequence) next() (ResponseStep, int) {
+	index := int((s.counter.Add(1) - 1) % int64(len(s.steps)))
+	return s.steps[index], index
+}
+
+// current returns the index of the step the next request will get
+func (s *responseSequence) current() int {
+	return int(s.counter.Load() % int64(len(s.steps)))
+}
+
+// peek returns what next would return, without advancing the sequence
+func (s *responseSequence) peek() (ResponseStep, int) {
+	index := s.current()
+	return s.steps[index], index
+}
+
+// ColdStartConfig makes the first request after an idle period slow
+type ColdStartConfig struct {
+	IdleMs  int `json:"idle_ms" yaml:"idle_ms"`   // idle time after which the next request is cold
+	DelayMs int `json:"delay_ms" yaml:"delay_ms"` // delay of a cold request, replacing the normal one
+}
+
+// coldStartTracker decides which requests are cold
+type coldStartTracker struct {
+	mu       sync.Mutex
+	idle     time.Duration
+	delay    time.Duration
+	lastSeen time.Time // zero until the first request, which is always cold
+}
+
+// newColdStartTracker validates the config. It returns nil when cold_start is not configured.
+func newColdStartTracker(config *ColdStartConfig) (*coldStartTracker, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if config.IdleMs <= 0 || config.DelayMs < 0 {
+		return nil, fmt.Errorf("cold_start needs idle_ms > 0 and delay_ms >= 0, got idle_ms=%d delay_ms=%d", config.IdleMs, config.DelayMs)
+	}
+	return &coldStartTracker{
+		idle:  time.Duration(config.IdleMs) * time.Millisecond,
+		delay: time.Duration(config.DelayMs) * time.Millisecond,
+	}, nil
+}
+
+// hit records a request and reports whether it is a cold start. Only one of
+// several requests arriving together after an idle period is cold.
+func (t *coldStartTracker) hit(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cold := t.lastSeen.IsZero() || now.Sub(t.lastSeen) > t.idle
+	t.lastSeen = now
+	return cold
+}
+
+// cold reports whether a request arriving now would be a cold start, without recording it
+func (t *coldStartTracker) cold(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.lastSeen.IsZero() || now.Sub(t.lastSeen) > t.idle
+}
+
+// CircuitBreakerConfig simulates a backend guarded by a circuit breaker. The
+// failures are the webhook's own 5xx responses (status_code, sequence steps,
+// fail_first_n, handler timeouts, ...).
+type CircuitBreakerConfig struct {
+	FailureThreshold int `json:"failure_threshold" yaml:"failure_threshold"`                       // consecutive 5xx responses that open the circuit
+	OpenDurationMs   int `json:"open_duration_ms,omitempty" yaml:"open_duration_ms,omitempty"`     // defaults to 5000
+	HalfOpenRequests int `json:"half_open_requests,omitempty" yaml:"half_open_requests,omitempty"` // concurrent trials once half-open, defaults to 1
+	StatusCode       int `json:"status_code,omitempty" yaml:"status_code,omitempty"`               // answer while open, defaults to 503
+}
+
+// Circuit breaker states, as reported by the circuit_state metric
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half_open"
+)
+
+// circuitBreaker is the closed -> open -> half-open state machine of a webhook
+type circuitBreaker struct {
+	mu         sync.Mutex
+	threshold  int
+	openFor    time.Duration
+	trials     int
+	statusCode int
+	state      string
+	failures   int       // consecutive failures while closed
+	openedAt   time.Time // when the circuit last opened
+	inTrial    int       // half-open trials admitted and not yet finished
+}
+
+// newCircuitBreaker validates the config and applies defaults.
+// It returns nil when circuit_breaker is not configured.
+func newCircuitBreaker(config *CircuitBreakerConfig) (*circuitBreaker, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if config.FailureThreshold < 1 {
+		return nil, fmt.Errorf("circuit_breaker failure_threshold must be at least 1, got %d", config.FailureThreshold)
+	}
+	if config.OpenDurationMs < 0 || config.HalfOpenRequests < 0 {
+		return nil, fmt.Errorf("circuit_breaker open_duration_ms and half_open_requests must not be negative")
+	}
+	breaker := &circuitBreaker{
+		threshold:  config.FailureThreshold,
+		openFor:    time.Duration(config.OpenDurationMs) * time.Millisecond,
+		trials:     config.HalfOpenRequests,
+		statusCode: config.StatusCode,
+		state:      circuitClosed,
+	}
+	if breaker.openFor == 0 {
+		breaker.openFor = 5 * time.Second
+	}
+	if breaker.trials == 0 {
+		breaker.trials = 1
+	}
+	if breaker.statusCode == 0 {
+		breaker.statusCode = http.StatusServiceUnavailable
+	}
+	if breaker.statusCode < 400 || breaker.statusCode > 599 {
+		return nil, fmt.Errorf("circuit_breaker status_code must be a 4xx or 5xx code, got %d", breaker.statusCode)
+	}
+	return breaker, nil
+}
+
+// allow decides whether a request is served. An open circuit rejects requests
+// until open_duration_ms is over, then turns half-open and admits up to
+// half_open_requests trials at a time. retryAfter is set for rejections.
+func (b *circuitBreaker) allow(now time.Time) (allowed, trial bool, retryAfter time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitOpen {
+		if remaining := b.openFor - now.Sub(b.openedAt); remaining > 0 {
+			return false, false, remaining
 		}
-		if config.Server.Host == "" {
-			config.Server.Host = "localhost"
+		b.state = circuitHalfOpen
+		b.inTrial = 0
+	}
+	if b.state == circuitHalfOpen {
+		if b.inTrial >= b.trials {
+			return false, false, time.Second
 		}
-		return server, config
+		b.inTrial++
+		return true, true, 0
 	}
+	return true, false, 0
 }
 
-func (ws *WebhookServer) loadDefaultWebhooks() {
-	// Create default webhooks (fallback)
-	defaultWebhook := &Webhook{
-		ID:   "default",
-		Name: "Default Webhook",
-		Path: "/webhook",
-		Config: WebhookConfig{
-			StatusCode:    200,
-			ContentType:   "application/json",
-			ResponseBody:  `{"message": "Request received"}`,
-			Timeout:       0,
-			Headers:       make(map[string]string),
-			EnableLogging: true,
-		},
-		Calculator: NewTPSCalculator(),
-		CreatedAt:  time.Now(),
+// peek reports what allow would decide now, without moving the state or taking a trial
+func (b *circuitBreaker) peek(now time.Time) (allowed bool, retryAfter time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitOpen {
+		if remaining := b.openFor - now.Sub(b.openedAt); remaining > 0 {
+			return false, remaining
+		}
+		// allow would turn half-open with no trials running
+		return true, 0
+	}
+	if b.state == circuitHalfOpen && b.inTrial >= b.trials {
+		return false, time.Second
 	}
+	return true, 0
+}
 
-	fastWebhook := &Webhook{
-		ID:   "fast",
-		Name: "Fast Webhook",
-		Path: "/webhook/fast",
-		Config: WebhookConfig{
-			StatusCode:    200,
-			ContentType:   "application/json",
-			ResponseBody:  `{"message": "Fast response", "delay": "0ms"}`,
-			Timeout:       0,
-			Headers:       make(map[string]string),
-			EnableLogging: false,
-		},
-		Calculator: NewTPSCalculator(),
-		CreatedAt:  time.Now(),
+// record feeds back the outcome of an allowed request and returns the state
+// the circuit moved to, or "" when it didn't change. A trial's outcome closes
+// or reopens the circuit.
+func (b *circuitBreaker) record(failed, trial bool, now time.Time) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if trial {
+		if b.state != circuitHalfOpen {
+			return ""
+		}
+		b.inTrial--
+		if failed {
+			b.state, b.openedAt = circuitOpen, now
+			return circuitOpen
+		}
+		b.state, b.failures = circuitClosed, 0
+		return circuitClosed
 	}
+	// Requests admitted before the circuit opened don't count any more
+	if b.state != circuitClosed {
+		return ""
+	}
+	if !failed {
+		b.failures = 0
+		return ""
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.state, b.openedAt, b.failures = circuitOpen, now, 0
+		return circuitOpen
+	}
+	return ""
+}
 
-	slowWebhook := &Webhook{
-		ID:   "slow",
-		Name: "Slow Webhook",
-		Path: "/webhook/slow",
-		Config: WebhookConfig{
-			StatusCode:    200,
-			ContentType:   "application/json",
-			ResponseBody:  `{"message": "Slow response", "delay": "2000ms"}`,
-			Timeout:       2000,
-			Headers:       make(map[string]string),
-			EnableLogging: true,
-		},
-		Calculator: NewTPSCalculator(),
-		CreatedAt:  time.Now(),
+// release frees the slot of a trial that ended without a response, e.g. a
+// client disconnect, without deciding the circuit's state
+func (b *circuitBreaker) release(trial bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if trial && b.state == circuitHalfOpen {
+		b.inTrial--
 	}
+}
 
-	ws.webhooks["default"] = defaultWebhook
-	ws.webhooks["fast"] = fastWebhook
-	ws.webhooks["slow"] = slowWebhook
+// current returns the state for metrics; an open circuit whose duration is
+// over is reported as half-open
+func (b *circuitBreaker) current(now time.Time) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitOpen && now.Sub(b.openedAt) >= b.openFor {
+		return circuitHalfOpen
+	}
+	return b.state
 }
 
-func (ws *WebhookServer) loadWebhooksFromConfig(config *WebhookConfigFile) {
-	for _, webhookConfig := range config.DefaultWebhooks {
-		if webhookConfig.Config.Headers == nil {
-			webhookConfig.Config.Headers = make(map[string]string)
+// FailOnRepeatConfig rejects clients that keep sending an identical body
+type FailOnRepeatConfig struct {
+	Count      int `json:"count" yaml:"count"`                                 // consecutive identical bodies allowed
+	StatusCode int `json:"status_code,omitempty" yaml:"status_code,omitempty"` // defaults to 409
+}
+
+// repeatTracker counts consecutive requests with the same body hash
+type repeatTracker struct {
+	mu         sync.Mutex
+	count      int
+	statusCode int
+	lastHash   [sha256.Size]byte
+	streak     int
+}
+
+// newRepeatTracker validates the config and applies defaults.
+// It returns nil when fail_on_repeat is not configured.
+func newRepeatTracker(config *FailOnRepeatConfig) (*repeatTracker, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if config.Count < 1 {
+		return nil, fmt.Errorf("fail_on_repeat count must be at least 1, got %d", config.Count)
+	}
+	statusCode := config.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusConflict
+	}
+	if statusCode < 400 || statusCode > 599 {
+		return nil, fmt.Errorf("fail_on_repeat status_code must be a 4xx or 5xx code, got %d", statusCode)
+	}
+	return &repeatTracker{count: config.Count, statusCode: statusCode}, nil
+}
+
+// observe records a body and returns how many times in a row it has now been
+// seen, and whether that exceeds the allowed count
+func (r *repeatTracker) observe(body []byte) (int, bool) {
+	hash := sha256.Sum256(body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.streak > 0 && hash == r.lastHash {
+		r.streak++
+	} else {
+		r.lastHash = hash
+		r.streak = 1
+	}
+	return r.streak, r.streak > r.count
+}
+
+// defaultRedactedHeaders are masked in request logs unless LogHeaderRedact is set
+var defaultRedactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "X-Api-Key"}
+
+const (
+	defaultLogHeaderMaxBytes = 8192
+	redactedHeaderValue      = "***"
+)
+
+// logBodyFallbackBytes caps the logged body when log_body_json_paths can't be applied
+const logBodyFallbackBytes = 4096
+
+// jsonPath is a compiled dotted path into a JSON document, e.g. "items[0].sku".
+// Numeric segments index arrays; a leading "$." is allowed.
+type jsonPath struct {
+	raw      string
+	segments []string
+}
+
+// parseJSONPath compiles a path, rejecting empty segments and malformed indexes
+func parseJSONPath(raw string) (jsonPath, error) {
+	path := strings.TrimPrefix(strings.TrimPrefix(raw, "$"), ".")
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+	if path == "" {
+		return jsonPath{}, fmt.Errorf("json path %q is empty", raw)
+	}
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if segment == "" {
+			return jsonPath{}, fmt.Errorf("json path %q has an empty segment", raw)
 		}
-		
-		webhook := &Webhook{
-			ID:         webhookConfig.ID,
-			Name:       webhookConfig.Name,
-			Path:       webhookConfig.Path,
-			Config:     webhookConfig.Config,
-			Calculator: NewTPSCalculator(),
-			CreatedAt:  time.Now(),
+	}
+	if strings.Count(raw, "[") != strings.Count(raw, "]") {
+		return jsonPath{}, fmt.Errorf("json path %q has unbalanced brackets", raw)
+	}
+	return jsonPath{raw: raw, segments: segments}, nil
+}
+
+// lookup returns the value at the path in a decoded JSON document
+func (p jsonPath) lookup(doc interface{}) (interface{}, bool) {
+	current := doc
+	for _, segment := range p.segments {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, exists := node[segment]
+			if !exists {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
 		}
-		
-		ws.webhooks[webhookConfig.ID] = webhook
-		
-		// Register route for this webhook
-		ws.registerWebhookRoute(webhook)
 	}
+	return current, true
+}
+
+// extractBodyFields decodes a JSON body and returns the value at each path
+// (nil where a path is absent). ok is false when the body is not JSON.
+func extractBodyFields(body []byte, paths []jsonPath) (fields map[string]interface{}, ok bool) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, false
+	}
+	fields = make(map[string]interface{}, len(paths))
+	for _, path := range paths {
+		value, _ := path.lookup(doc)
+		fields[path.raw] = value
+	}
+	return fields, true
+}
+
+// Reserved label_counts keys for requests whose body yields no usable value
+const (
+	labelOther   = "other"    // past metrics_label_max_values distinct values, or too long
+	labelMissing = "missing"  // field absent or null
+	labelNotJSON = "not_json" // body is not JSON
+)
+
+// defaultMetricsLabelMaxValues bounds label_counts when metrics_label_max_values is unset
+const defaultMetricsLabelMaxValues = 100
+
+// maxMetricsLabelLength is the longest value counted under its own key
+const maxMetricsLabelLength = 128
+
+// metricsLabelValue returns the label_counts key for a request body: the
+// field's value (strings as-is, anything else JSON-encoded), or a reserved key
+func metricsLabelValue(body []byte, path jsonPath) string {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return labelNotJSON
+	}
+	value, ok := path.lookup(doc)
+	if !ok || value == nil {
+		return labelMissing
+	}
+	if text, isString := value.(string); isString {
+		return text
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return labelOther
+	}
+	return string(encoded)
+}
+
+// headerLogFilter decides which request headers are logged and how
+type headerLogFilter struct {
+	allow    map[string]bool // nil means every header not denied
+	deny     map[string]bool
+	redact   map[string]bool
+	maxBytes int
+}
+
+// canonicalHeaderSet builds a lookup set of canonical header names
+func canonicalHeaderSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+	}
+	return set
+}
+
+// newHeaderLogFilter compiles the header logging settings of a webhook config
+func newHeaderLogFilter(config WebhookConfig) (*headerLogFilter, error) {
+	if config.LogHeaderMaxBytes < 0 {
+		return nil, fmt.Errorf("log_header_max_bytes must not be negative, got %d", config.LogHeaderMaxBytes)
+	}
+	filter := &headerLogFilter{
+		deny:     canonicalHeaderSet(config.LogHeaderDenylist),
+		maxBytes: config.LogHeaderMaxBytes,
+	}
+	if len(config.LogHeaderAllowlist) > 0 {
+		filter.allow = canonicalHeaderSet(config.LogHeaderAllowlist)
+	}
+	if config.LogHeaderRedact != nil {
+		filter.redact = canonicalHeaderSet(config.LogHeaderRedact)
+	} else {
+		filter.redact = canonicalHeaderSet(defaultRedactedHeaders)
+	}
+	if filter.maxBytes == 0 {
+		filter.maxBytes = defaultLogHeaderMaxBytes
+	}
+	return filter, nil
+}
+
+// apply returns the headers to log, in name order, and whether any were
+// dropped because the byte cap was reached. Webhooks that never went through
+// compileConfig (the built-in defaults) get the default filter.
+func (f *headerLogFilter) apply(header http.Header) (map[string][]string, bool) {
+	if f == nil {
+		f, _ = newHeaderLogFilter(WebhookConfig{})
+	}
+	logged := make(map[string][]string)
+	size := 0
+	for _, name := range sortedKeys(header) {
+		if f.deny[name] || (f.allow != nil && !f.allow[name]) {
+			continue
+		}
+		values := header[name]
+		if f.redact[name] {
+			values = []string{redactedHeaderValue}
+		}
+		entrySize := len(name)
+		for _, value := range values {
+			entrySize += len(value)
+		}
+		if size+entrySize > f.maxBytes {
+			return logged, true
+		}
+		size += entrySize
+		logged[name] = values
+	}
+	return logged, false
+}
+
+// forwardClient is shared by all forwarding webhooks; deadlines come from the request context
+var forwardClient = &http.Client{
+	// Relay upstream redirects to the client instead of following them
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// hopByHopHeaders are connection-specific and must not be forwarded by proxies
+var hopByHopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// DelayDistribution samples a per-request delay in milliseconds.
+// Supported types: "constant" (uses Timeout), "uniform" (Min..Max),
+// "normal" (Mean, StdDev) and "exponential" (Lambda, mean delay is 1/Lambda).
+// Samples are clamped to 0..MaxDelay (0 means no upper bound).
+type DelayDistribution struct {
+	Type     string  `json:"type" yaml:"type"`
+	Min      int     `json:"min,omitempty" yaml:"min,omitempty"`
+	Max      int     `json:"max,omitempty" yaml:"max,omitempty"`
+	Mean     float64 `json:"mean,omitempty" yaml:"mean,omitempty"`
+	StdDev   float64 `json:"stddev,omitempty" yaml:"stddev,omitempty"`
+	Lambda   float64 `json:"lambda,omitempty" yaml:"lambda,omitempty"`
+	MaxDelay int     `json:"max_delay,omitempty" yaml:"max_delay,omitempty"`
+}
+
+// validate checks the distribution parameters
+func (d *DelayDistribution) validate() error {
+	if d.MaxDelay < 0 {
+		return errors.New("delay_distribution max_delay must not be negative")
+	}
+	switch d.Type {
+	case "constant":
+	case "uniform":
+		if d.Min < 0 || d.Max < d.Min {
+			return fmt.Errorf("uniform delay_distribution needs 0 <= min <= max, got min=%d max=%d", d.Min, d.Max)
+		}
+	case "normal":
+		if d.StdDev < 0 {
+			return fmt.Errorf("normal delay_distribution needs stddev >= 0, got %v", d.StdDev)
+		}
+	case "exponential":
+		if d.Lambda <= 0 {
+			return fmt.Errorf("exponential delay_distribution needs lambda > 0, got %v", d.Lambda)
+		}
+	default:
+		return fmt.Errorf("unknown delay_distribution type %q (use constant, uniform, normal or exponential)", d.Type)
+	}
+	return nil
+}
+
+// sample draws a delay in milliseconds, clamped to 0..MaxDelay
+func (d *DelayDistribution) sample(rnd *webhookRand, baseTimeout int) float64 {
+	var delayMs float64
+	switch d.Type {
+	case "constant":
+		delayMs = float64(baseTimeout)
+	case "uniform":
+		delayMs = float64(d.Min) + rnd.Float64()*float64(d.Max-d.Min)
+	case "normal":
+		delayMs = d.Mean + rnd.NormFloat64()*d.StdDev
+	case "exponential":
+		delayMs = rnd.ExpFloat64() / d.Lambda
+	}
+
+	if delayMs < 0 {
+		delayMs = 0
+	}
+	if d.MaxDelay > 0 && delayMs > float64(d.MaxDelay) {
+		delayMs = float64(d.MaxDelay)
+	}
+	return delayMs
+}
+
+// BackoffConfig adds an escalating delay for client IPs that keep hitting a webhook.
+// The n-th hit within the cooldown gets InitialDelay * Multiplier^(n-2) extra
+// milliseconds (the first hit is not delayed), capped at MaxDelay.
+type BackoffConfig struct {
+	InitialDelay  int     `json:"initial_delay" yaml:"initial_delay"`                         // in milliseconds
+	Multiplier    float64 `json:"multiplier,omitempty" yaml:"multiplier,omitempty"`           // defaults to 2
+	MaxDelay      int     `json:"max_delay,omitempty" yaml:"max_delay,omitempty"`             // in milliseconds, defaults to 30000
+	Cooldown      int     `json:"cooldown,omitempty" yaml:"cooldown,omitempty"`               // in milliseconds without hits before an IP is forgiven, defaults to 10000
+	MaxTrackedIPs int     `json:"max_tracked_ips,omitempty" yaml:"max_tracked_ips,omitempty"` // defaults to 1000
+}
+
+// SignatureVerification checks a GitHub/Stripe-style HMAC of the raw request body.
+// The header value is Prefix followed by the digest, e.g. "sha256=<hex>".
+// The same settings describe response_signing, which signs response bodies.
+type SignatureVerification struct {
+	Header    string `json:"header,omitempty" yaml:"header,omitempty"`       // defaults to X-Signature
+	Secret    string `json:"secret" yaml:"secret"`                           // HMAC key
+	Algorithm string `json:"algorithm,omitempty" yaml:"algorithm,omitempty"` // sha1, sha256 (default) or sha512
+	Prefix    string `json:"prefix,omitempty" yaml:"prefix,omitempty"`       // stripped from the header value before comparing
+	Encoding  string `json:"encoding,omitempty" yaml:"encoding,omitempty"`   // hex (default) or base64
+}
+
+// signatureHashes maps supported algorithm names to hash constructors
+var signatureHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// withDefaults validates the settings of the named config option and fills in
+// defaults, using defaultHeader when no header is set
+func (v SignatureVerification) withDefaults(option, defaultHeader string) (SignatureVerification, error) {
+	if v.Secret == "" {
+		return v, fmt.Errorf("%s secret must not be empty", option)
+	}
+	if v.Header == "" {
+		v.Header = defaultHeader
+	}
+	v.Algorithm = strings.ToLower(v.Algorithm)
+	if v.Algorithm == "" {
+		v.Algorithm = "sha256"
+	}
+	if _, ok := signatureHashes[v.Algorithm]; !ok {
+		return v, fmt.Errorf("unknown %s algorithm %q (use sha1, sha256 or sha512)", option, v.Algorithm)
+	}
+	v.Encoding = strings.ToLower(v.Encoding)
+	if v.Encoding == "" {
+		v.Encoding = "hex"
+	}
+	if v.Encoding != "hex" && v.Encoding != "base64" {
+		return v, fmt.Errorf("unknown %s encoding %q (use hex or base64)", option, v.Encoding)
+	}
+	return v, nil
+}
+
+// sign returns the header value for body: Prefix followed by the encoded HMAC
+func (v *SignatureVerification) sign(body []byte) string {
+	mac := hmac.New(signatureHashes[v.Algorithm], []byte(v.Secret))
+	mac.Write(body)
+	if v.Encoding == "base64" {
+		return v.Prefix + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	return v.Prefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify reports whether the signature header matches the HMAC of body.
+// An empty string means the signature is valid; otherwise it explains why not.
+func (v *SignatureVerification) verify(header string, body []byte) string {
+	if header == "" {
+		return "missing " + v.Header + " header"
+	}
+	provided := strings.TrimPrefix(header, v.Prefix)
+	var signature []byte
+	var err error
+	if v.Encoding == "base64" {
+		signature, err = base64.StdEncoding.DecodeString(provided)
+	} else {
+		signature, err = hex.DecodeString(provided)
+	}
+	if err != nil {
+		return "malformed signature"
+	}
+
+	mac := hmac.New(signatureHashes[v.Algorithm], []byte(v.Secret))
+	mac.Write(body)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return "signature mismatch"
+	}
+	return ""
+}
+
+// queryResponse looks up the response body for the request's query_response_param
+// value. found is false for values missing from the map (and requests without
+// the parameter); the body is then the default one, or a 404 if configured.
+func (w *Webhook) queryResponse(c *gin.Context) (body string, found bool) {
+	body, found = w.Config.QueryResponseMap[c.Query(w.Config.QueryResponseParam)]
+	return body, found
+}
+
+// queryNotFound is the 404 body for values missing from query_response_map
+func (w *Webhook) queryNotFound(c *gin.Context) gin.H {
+	return gin.H{
+		"error": "No response for this " + w.Config.QueryResponseParam,
+		"value": c.Query(w.Config.QueryResponseParam),
+	}
+}
+
+// uriTooLong reports whether the request URI exceeds max_uri_length, and its length
+func (w *Webhook) uriTooLong(request *http.Request) (int, bool) {
+	uri := request.RequestURI
+	if uri == "" {
+		// Requests built in-process (match, self-test) have no RequestURI
+		uri = request.URL.RequestURI()
+	}
+	return len(uri), w.Config.MaxURILength > 0 && len(uri) > w.Config.MaxURILength
+}
+
+// uriTooLongBody is the 414 body for requests over max_uri_length
+func (w *Webhook) uriTooLongBody(length int) gin.H {
+	return gin.H{"error": "URI too long", "uri_length": length, "max_uri_length": w.Config.MaxURILength}
+}
+
+// missingHeaders returns the required headers the request lacks, and the status to reject it with
+func (w *Webhook) missingHeaders(c *gin.Context) ([]string, int) {
+	var missing []string
+	for _, name := range w.Config.RequiredHeaders {
+		if c.GetHeader(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	status := w.Config.RequiredHeadersStatus
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	return missing, status
+}
+
+// checkSignature verifies the request's signature, restoring the body for later readers
+func (w *Webhook) checkSignature(c *gin.Context) string {
+	if w.signature == nil {
+		return ""
+	}
+	body, err := peekRequestBody(c)
+	if err != nil {
+		return "failed to read request body"
+	}
+	return w.signature.verify(c.GetHeader(w.signature.Header), body)
+}
+
+type Webhook struct {
+	ID          string         `json:"id" yaml:"id"`
+	Name        string         `json:"name" yaml:"name"`
+	Path        string         `json:"path" yaml:"path"`
+	Tags        []string       `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Config      WebhookConfig  `json:"config" yaml:"config"`
+	Calculator  *TPSCalculator `json:"-" yaml:"-"`
+	CreatedAt   time.Time      `json:"created_at" yaml:"created_at"`
+	LastRequest *time.Time     `json:"last_request,omitempty" yaml:"last_request,omitempty"`
+	ExpiresAt   *time.Time     `json:"expires_at,omitempty" yaml:"expires_at,omitempty"` // set from the create request's ttl
+	Builtin     bool           `json:"builtin,omitempty" yaml:"builtin,omitempty"`       // loaded at startup (config.yaml or the defaults)
+
+	// Runtime state compiled from Config by compileConfig
+	compiled        *WebhookConfig // Config as of the last successful compile
+	requestSchema   *jsonschema.Schema
+	backoff         *ipBackoffTracker
+	capture         *bodyCapture
+	bodyTemplate    *template.Template
+	bodyFilePath    string // response_body_file resolved under files_dir
+	limiter         *concurrencyLimiter
+	etag            string // precomputed for static bodies; file and template bodies are hashed per response
+	headerLog       *headerLogFilter
+	logBodyPaths    []jsonPath
+	metricsLabel    *jsonPath
+	signature       *SignatureVerification // SignatureVerification with defaults applied
+	responseSigning *SignatureVerification // ResponseSigning with defaults applied
+	repeat          *repeatTracker
+	delayPattern    *delayPattern
+	coldStart       *coldStartTracker
+	circuit         *circuitBreaker
+	sequence        *responseSequence
+	bodyRotation    *bodyRotation
+	abSplit         *abSplit
+	rand            *webhookRand
+	startup         *startupGate
+}
+
+// templateData is the data available to response body templates,
+// e.g. {{ .Method }}, {{ .Query "id" }}, {{ .Header "X-Api-Key" }} or {{ .Param "orderId" }}
+type templateData struct {
+	Method string
+	Path   string
+	Body   string
+	ctx    *gin.Context
+}
+
+// Query returns a query parameter of the request
+func (d templateData) Query(name string) string {
+	return d.ctx.Query(name)
+}
+
+// Header returns a header of the request
+func (d templateData) Header(name string) string {
+	return d.ctx.GetHeader(name)
+}
+
+// Param returns a parameter of the webhook path, e.g. orderId for /orders/:orderId
+func (d templateData) Param(name string) string {
+	return d.ctx.Param(name)
+}
+
+type WebhookConfigFile struct {
+	Server struct {
+		Port     int    `yaml:"port"`
+		Host     string `yaml:"host"`
+		CatchAll bool   `yaml:"catch_all"` // route unmatched paths to the "catchall" webhook
+
+		// Cap on simultaneous TCP connections; further connections are not
+		// accepted until one closes. 0 means unlimited
+		MaxConnections int `yaml:"max_connections"`
+
+		// Upper bound on registered webhooks for API creates; 0 means unlimited
+		MaxWebhooks int `yaml:"max_webhooks"`
+
+		// Directory that file paths in webhook configs (request_schema_file,
+		// response_body_file) are resolved against; they may not be absolute or leave it. Default "."
+		FilesDir string `yaml:"files_dir"`
+
+		// Directory capture_bodies_to files are written to, under the same
+		// rules as FilesDir. Default "captures"
+		CaptureDir string `yaml:"capture_dir"`
+
+		// Ceiling on memory held by the recent request and error buffers;
+		// the oldest entries are evicted beyond it. 0 means unlimited
+		MaxBufferMemoryBytes int64 `yaml:"max_buffer_memory_bytes"`
+
+		// Where the web interface under /static is served from: "embedded" (compiled
+		// into the binary), "disk" (./static) or "auto" (disk when present, the default)
+		StaticSource string `yaml:"static_source"`
+
+		// Log a summary line per active webhook this often, e.g. "10s"; 0 disables
+		SummaryLogInterval time.Duration `yaml:"summary_log_interval"`
+
+		// Send a synthetic request to every webhook at startup and log the result;
+		// with SelfTestRequired a failure aborts startup
+		SelfTest         bool `yaml:"self_test"`
+		SelfTestRequired bool `yaml:"self_test_required"`
+
+		// Delays longer than this are skipped and answered immediately with
+		// MaxEffectiveDelayStatus (default 202); 0 disables the cap
+		MaxEffectiveDelay       time.Duration `yaml:"max_effective_delay"`
+		MaxEffectiveDelayStatus int           `yaml:"max_effective_delay_status"`
+
+		// Report not ready on /readyz for this long after start, e.g. "30s"; with
+		// StartupBlockWebhooks every webhook answers 503 during that window too
+		StartupDelay         time.Duration `yaml:"startup_delay"`
+		StartupBlockWebhooks bool          `yaml:"startup_block_webhooks"`
+
+		// http.Server timeouts, e.g. "30s"
+		ReadTimeout       time.Duration `yaml:"read_timeout"`
+		WriteTimeout      time.Duration `yaml:"write_timeout"`
+		IdleTimeout       time.Duration `yaml:"idle_timeout"`
+		ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	} `yaml:"server"`
+	Health struct {
+		// Window of recent activity GET /api/webhooks/:id/health looks at (default 60s)
+		Window time.Duration `yaml:"window"`
+		// 5xx share of recent requests above which a webhook is degraded (default 0.1)
+		DegradedErrorRate float64 `yaml:"degraded_error_rate"`
+	} `yaml:"health"`
+	Metrics struct {
+		// Latency percentiles reported as p<N>_ms, e.g. [50, 90, 99.9] (default [50, 95, 99])
+		Percentiles []float64 `yaml:"percentiles"`
+		// Decimal places TPS values are rounded to in API output (default 2, -1 = full precision)
+		TPSPrecision *int `yaml:"tps_precision"`
+	} `yaml:"metrics"`
+	Prometheus struct {
+		// Histogram bucket upper bounds in seconds for the /metrics latency histograms
+		LatencyBuckets []float64 `yaml:"latency_buckets"`
+	} `yaml:"prometheus"`
+	Logging struct {
+		LogFile   string `yaml:"log_file"`
+		LogLevel  string `yaml:"log_level"`
+		LogFormat string `yaml:"log_format"`
+		// Rotate the log file when it exceeds this size (default 100)
+		MaxSizeMB int `yaml:"max_size_mb"`
+		// Rotated files to keep, and days to keep them; 0 keeps them all
+		MaxBackups int `yaml:"max_backups"`
+		MaxAgeDays int `yaml:"max_age_days"`
+		// Gzip rotated files
+		Compress bool `yaml:"compress"`
+	} `yaml:"logging"`
+	DefaultWebhooks []WebhookConfigEntry `yaml:"default_webhooks"`
+}
+
+// WebhookConfigEntry is one webhook in the config file
+type WebhookConfigEntry struct {
+	ID     string        `yaml:"id"`
+	Name   string        `yaml:"name"`
+	Path   string        `yaml:"path,omitempty"`
+	Tags   []string      `yaml:"tags,omitempty"`
+	Config WebhookConfig `yaml:"config"`
+}
+
+// catchAllWebhookID is the reserved webhook ID used for unmatched paths
+const catchAllWebhookID = "catchall"
+
+type TPSCalculator struct {
+	mu                sync.RWMutex
+	requestCount      int64
+	startTime         time.Time
+	lastTime          time.Time
+	isActive          bool
+	armedAt           time.Time // creation or last reset, for time_to_first_request_ms
+	methodCounts      map[string]int64
+	methodTimes       map[string]*methodLatency // completed-request latency per HTTP method
+	patternHits       map[string]int64          // requests per delay_pattern rule that fired
+	labelCounts       map[string]int64          // requests per metrics_label_json_path value
+	variantCounts     map[string]int64          // requests per ab_split variant
+	minInterval       time.Duration             // shortest gap between consecutive requests
+	maxInterval       time.Duration             // longest gap between consecutive requests
+	hasInterval       bool                      // true once at least two requests were recorded
+	cancelled         int64                     // requests abandoned by the client during the delay
+	schemaFails       int64                     // requests rejected by request schema validation
+	signatureFails    int64                     // requests rejected by HMAC signature verification
+	missingHeaders    int64                     // requests rejected for lacking a required header
+	uriTooLong        int64                     // requests rejected with 414 for exceeding max_uri_length
+	fanIn             int64                     // requests counted here on behalf of a fan_out_to webhook
+	repeatFails       int64                     // requests rejected by fail_on_repeat
+	coldStarts        int64                     // requests that got the cold_start delay
+	circuitOpens      int64                     // times the circuit breaker opened
+	circuitRejections int64                     // requests answered fast by an open circuit
+	renderErrors      int64                     // responses whose body could not be produced as configured
+	timeoutErrs       int64                     // requests that exceeded the webhook's handler_timeout
+	delaySkipped      int64                     // requests whose delay exceeded max_effective_delay
+	notModified       int64                     // conditional requests answered with 304
+	chunkedResponses  int64                     // responses sent with force_chunked
+	failFirst         int64                     // requests failed by fail_first_n since the last reset
+	failFirstSeen     int64                     // requests counted against fail_first_n, paused or not
+	bodyCount         int64                     // requests with a known body size
+	bodyBytes         int64                     // summed request body sizes
+	bodyMax           int64                     // largest request body seen
+	bodyDelayCount    int64                     // requests that got a delay_per_kb delay
+	bodyDelayTotal    time.Duration             // summed delay_per_kb delays
+	bodyDelayMax      time.Duration             // largest delay_per_kb delay
+	queueRejects      int64                     // requests rejected with 503 because the max_queue was full
+	queuedCount       int64                     // requests that had to wait for a concurrency slot
+	queueWait         time.Duration             // summed wait of queued requests
+	queueWaitMax      time.Duration             // longest wait for a concurrency slot
+	statusClass       [6]int64                  // responses by status class, indexed by the leading digit
+	upstreamOK        int64                     // successful forwards to the upstream
+	upstreamErrs      int64                     // forwards that failed (answered with 502)
+	upstreamTime      time.Duration             // summed latency of successful forwards
+	upstreamMax       time.Duration             // slowest successful forward
+	latencies         []time.Duration           // ring buffer of the most recent request latencies
+	latencyNext       int                       // next write position in latencies once it is full
+	delays            []time.Duration           // ring buffer of the most recent realized artificial delays
+	delayNext         int                       // next write position in delays once it is full
+	deadlineUse       []float64                 // ring buffer of the most recent latency/deadline_budget_ms ratios
+	deadlineNext      int                       // next write position in deadlineUse once it is full
+	deadlineExceeded  int64                     // requests slower than deadline_budget_ms
+	clientSketch      *hyperLogLog              // distinct client IPs, created on first use
+	inFlight          atomic.Int64              // requests currently being processed (past any concurrency queue)
+	paused            bool                      // while paused nothing is recorded
+	pausedAt          time.Time                 // when the current pause began
+	pendingPause      time.Duration             // paused time since the last recorded request
+	pausedTotal       time.Duration             // paused time between the first and last request, left out of TPS
+	buckets           []secondBucket            // per-second request counts, indexed by unix second modulo bucketRetentionSeconds
+	history           []RunSummary              // summaries of previous runs, archived by Reset (oldest first)
+}
+
+// methodLatency accumulates completed-request latency for one HTTP method
+type methodLatency struct {
+	count int64
+	total time.Duration
+}
+
+// latencySampleSize is how many recent request latencies are kept for percentiles
+const latencySampleSize = 1024
+
+// bucketRetentionSeconds is how many seconds of per-second counts are kept
+const bucketRetentionSeconds = 3600
+
+// recentSecondsWindow is how many per-second counts ?detailed=true metrics include
+const recentSecondsWindow = 60
+
+// secondBucket holds the request count for one unix second
+type secondBucket struct {
+	Second       int64 `json:"-"`
+	Count        int64 `json:"count"`
+	ServerErrors int64 `json:"-"` // 5xx responses sent during this second
+}
+
+// runHistorySize is how many archived runs each calculator keeps
+const runHistorySize = 20
+
+// RunSummary describes one metrics period that ended with a reset
+type RunSummary struct {
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	TotalRequests int64     `json:"total_requests"`
+	TPS           float64   `json:"tps"`
+	PeakTPS       int64     `json:"peak_tps"` // most requests seen in a single second
+}
+
+// HistogramPoint is one (possibly downsampled) histogram bucket
+type HistogramPoint struct {
+	Time  string `json:"time"`
+	Count int64  `json:"count"`
+}
+
+type WebhookServer struct {
+	webhooks  map[string]*Webhook
+	routes    map[string]string // registered webhook path -> ID of the webhook serving it ("" once deleted)
+	mu        sync.RWMutex
+	router    *gin.Engine
+	startedAt time.Time
+
+	// calculator tracks requests across every webhook
+	calculator *TPSCalculator
+
+	// maxWebhooks limits API-created webhooks (0 = unlimited)
+	maxWebhooks int
+
+	// Delays above maxEffectiveDelay are skipped and answered with skippedDelayStatus
+	maxEffectiveDelay  time.Duration
+	skippedDelayStatus int
+
+	// config is the effective config file (after env overrides and defaults)
+	config *WebhookConfigFile
+
+	// Prometheus latency histograms per webhook ID, with shared bucket bounds
+	histogramsMu   sync.Mutex
+	histograms     map[string]*latencyHistograms
+	latencyBuckets []float64
+
+	// Directories webhook file paths are resolved against, from the server section
+	dirs webhookDirs
+
+	// Latency percentiles reported in metrics, from metrics.percentiles
+	percentiles []float64
+	// Decimal places TPS values are rounded to, from metrics.tps_precision
+	tpsPrecision int
+
+	// Thresholds for GET /api/webhooks/:id/health
+	healthWindow      time.Duration
+	degradedErrorRate float64
+
+	// Summaries of the most recent requests per webhook ID
+	recentMu sync.Mutex
+	recent   map[string]*requestBuffer
+
+	// Per-client-IP request counts per webhook ID
+	clientsMu sync.Mutex
+	clients   map[string]*clientTracker
+
+	// Most recent error events per webhook ID
+	errorsMu sync.Mutex
+	errors   map[string]*errorBuffer
+
+	// Memory held by the request and error buffers, bounded by max_buffer_memory_bytes
+	bufferMemory *memoryBudget
+
+	// Running background goroutines by task name, for GET /api/debug/stats
+	tasksMu sync.Mutex
+	tasks   map[string]int
+
+	// Not ready until server.startup_delay has passed; nil without a delay
+	startup               *startupGate
+	startupBlocksWebhooks bool
+}
+
+// startupGate reports not ready until readyAt. Webhook gates log the
+// transition once, on the first request after they open.
+type startupGate struct {
+	readyAt  time.Time
+	announce sync.Once
+}
+
+// newStartupGate returns a gate opening delay after start, or nil without a delay
+func newStartupGate(start time.Time, delay time.Duration) *startupGate {
+	if delay <= 0 {
+		return nil
+	}
+	return &startupGate{readyAt: start.Add(delay)}
+}
+
+// remaining returns how long until the gate opens; 0 once open or for a nil gate
+func (g *startupGate) remaining(now time.Time) time.Duration {
+	if g == nil {
+		return 0
+	}
+	if left := g.readyAt.Sub(now); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// retryAfterSeconds formats a wait as whole seconds for a Retry-After header
+func retryAfterSeconds(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+// announceReady logs when the server's startup delay is over
+func (ws *WebhookServer) announceReady(ctx context.Context) {
+	defer ws.trackTask("startup_timer")()
+	timer := time.NewTimer(ws.startup.remaining(time.Now()))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+		logrus.Info("✅ Startup delay over, server is ready")
+	}
+}
+
+// trackTask counts a background goroutine as running until the returned func is called
+func (ws *WebhookServer) trackTask(name string) func() {
+	ws.tasksMu.Lock()
+	defer ws.tasksMu.Unlock()
+
+	if ws.tasks == nil {
+		ws.tasks = make(map[string]int)
+	}
+	ws.tasks[name]++
+	return func() {
+		ws.tasksMu.Lock()
+		defer ws.tasksMu.Unlock()
+
+		ws.tasks[name]--
+	}
+}
+
+// runningTasks returns a copy of the background goroutine counts
+func (ws *WebhookServer) runningTasks() map[string]int {
+	ws.tasksMu.Lock()
+	defer ws.tasksMu.Unlock()
+
+	tasks := make(map[string]int, len(ws.tasks))
+	for name, count := range ws.tasks {
+		tasks[name] = count
+	}
+	return tasks
+}
+
+// ErrorEvent is one recent failure of a webhook
+type ErrorEvent struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// errorBufferSize is how many recent error events are kept per webhook
+const errorBufferSize = 50
+
+// errorBuffer is a ring buffer of recent error events
+type errorBuffer struct {
+	mu     sync.Mutex
+	events []ErrorEvent // oldest first
+	bytes  int64
+	budget *memoryBudget
+}
+
+// memSize estimates the memory held by an event
+func (e ErrorEvent) memSize() int64 {
+	return 64 + int64(len(e.Type)+len(e.Message)+len(e.RequestID))
+}
+
+// add stores an event, dropping the oldest once the buffer is full or the
+// server's buffer memory budget is exceeded
+func (b *errorBuffer) add(event ErrorEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.events = append(b.events, event)
+	b.grow(event.memSize())
+	for len(b.events) > errorBufferSize || (len(b.events) > 0 && b.budget.exceeded()) {
+		b.grow(-b.events[0].memSize())
+		b.events = b.events[1:]
+	}
+}
+
+// grow accounts n more bytes (or fewer, if negative) to the buffer and the budget
+func (b *errorBuffer) grow(n int64) {
+	b.bytes += n
+	b.budget.add(n)
+}
+
+// release returns the buffer's memory to the budget when it is dropped
+func (b *errorBuffer) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.grow(-b.bytes)
+	b.events = nil
+}
+
+// snapshot returns the events, newest first
+func (b *errorBuffer) snapshot() []ErrorEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make([]ErrorEvent, 0, len(b.events))
+	for i := len(b.events) - 1; i >= 0; i-- {
+		events = append(events, b.events[i])
+	}
+	return events
+}
+
+// recordError keeps an error event for GET /api/webhooks/:id/errors
+func (ws *WebhookServer) recordError(webhook *Webhook, errorType, message, requestID string) {
+	ws.errorsMu.Lock()
+	buffer, exists := ws.errors[webhook.ID]
+	if !exists {
+		buffer = &errorBuffer{budget: ws.bufferMemory}
+		ws.errors[webhook.ID] = buffer
+	}
+	ws.errorsMu.Unlock()
+
+	buffer.add(ErrorEvent{Time: time.Now(), Type: errorType, Message: message, RequestID: requestID})
+}
+
+// recentErrors returns the webhook's recent error events, newest first
+func (ws *WebhookServer) recentErrors(id string) []ErrorEvent {
+	ws.errorsMu.Lock()
+	buffer, exists := ws.errors[id]
+	ws.errorsMu.Unlock()
+
+	if !exists {
+		return []ErrorEvent{}
+	}
+	return buffer.snapshot()
+}
+
+// maxTrackedClients bounds how many client IPs are counted per webhook
+const maxTrackedClients = 1000
+
+// ClientStats is the request count of one client IP
+type ClientStats struct {
+	IP       string    `json:"ip"`
+	Requests int64     `json:"requests"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// clientTracker counts requests per client IP. When full, the client with
+// the fewest requests (the oldest on ties) is evicted, so heavy hitters stay.
+type clientTracker struct {
+	mu      sync.Mutex
+	clients map[string]*ClientStats
+}
+
+// hit counts a request from ip
+func (t *clientTracker) hit(ip string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if stats, exists := t.clients[ip]; exists {
+		stats.Requests++
+		stats.LastSeen = now
+		return
+	}
+	if len(t.clients) >= maxTrackedClients {
+		var victim *ClientStats
+		for _, stats := range t.clients {
+			if victim == nil || stats.Requests < victim.Requests ||
+				(stats.Requests == victim.Requests && stats.LastSeen.Before(victim.LastSeen)) {
+				victim = stats
+			}
+		}
+		delete(t.clients, victim.IP)
+	}
+	t.clients[ip] = &ClientStats{IP: ip, Requests: 1, LastSeen: now}
+}
+
+// top returns the n clients with the most requests and the number tracked
+func (t *clientTracker) top(n int) ([]ClientStats, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	all := make([]ClientStats, 0, len(t.clients))
+	for _, stats := range t.clients {
+		all = append(all, *stats)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Requests != all[j].Requests {
+			return all[i].Requests > all[j].Requests
+		}
+		return all[i].IP < all[j].IP
+	})
+	if n < len(all) {
+		all = all[:n]
+	}
+	return all, len(t.clients)
+}
+
+// clientTracker returns the webhook's client IP counts, creating them on first use
+func (ws *WebhookServer) clientTracker(id string) *clientTracker {
+	ws.clientsMu.Lock()
+	defer ws.clientsMu.Unlock()
+
+	tracker, exists := ws.clients[id]
+	if !exists {
+		tracker = &clientTracker{clients: make(map[string]*ClientStats)}
+		ws.clients[id] = tracker
+	}
+	return tracker
+}
+
+// forgetClients drops the webhook's client IP counts
+func (ws *WebhookServer) forgetClients(id string) {
+	ws.clientsMu.Lock()
+	defer ws.clientsMu.Unlock()
+
+	delete(ws.clients, id)
+}
+
+// RequestSummary describes one handled webhook request
+type RequestSummary struct {
+	Time          time.Time    `json:"time"`
+	RequestID     string       `json:"request_id,omitempty"`
+	Method        string       `json:"method"`
+	Path          string       `json:"path"`
+	Query         string       `json:"query,omitempty"`
+	IP            string       `json:"ip"`
+	Status        int          `json:"status"`
+	ContentLength int64        `json:"content_length"`
+	LatencyMs     float64      `json:"latency_ms"`
+	Trace         []TraceEvent `json:"trace,omitempty"` // with trace_requests
+}
+
+// TraceEvent is one lifecycle stage of a traced request
+type TraceEvent struct {
+	Stage     string    `json:"stage"`
+	At        time.Time `json:"at"`
+	ElapsedMs float64   `json:"elapsed_ms"` // since the request was received
+}
+
+// requestTrace collects the lifecycle stages of one request. A nil trace
+// (trace_requests off) ignores marks, so untraced requests pay nothing.
+type requestTrace struct {
+	start  time.Time
+	events []TraceEvent
+}
+
+// String formats the stages with their elapsed milliseconds for logs,
+// e.g. "received=0 delay_start=0.03 delay_end=100.29"
+func (t *requestTrace) String() string {
+	parts := make([]string, len(t.events))
+	for i, event := range t.events {
+		parts[i] = event.Stage + "=" + strconv.FormatFloat(math.Round(event.ElapsedMs*100)/100, 'f', -1, 64)
+	}
+	return strings.Join(parts, " ")
+}
+
+// newRequestTrace starts a trace at the time the request was received
+func newRequestTrace(received time.Time) *requestTrace {
+	trace := &requestTrace{start: received, events: make([]TraceEvent, 0, 8)}
+	trace.events = append(trace.events, TraceEvent{Stage: "received", At: received})
+	return trace
+}
+
+// mark records that the request reached a stage now
+func (t *requestTrace) mark(stage string) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.events = append(t.events, TraceEvent{
+		Stage:     stage,
+		At:        now,
+		ElapsedMs: float64(now.Sub(t.start)) / float64(time.Millisecond),
+	})
+}
+
+// requestBufferSize is how many recent request summaries are kept per webhook
+const requestBufferSize = 1000
+
+// requestBuffer is a ring buffer of recent request summaries
+type requestBuffer struct {
+	mu      sync.Mutex
+	entries []RequestSummary // oldest first
+	bytes   int64
+	budget  *memoryBudget
+}
+
+// memSize estimates the memory held by a summary
+func (s RequestSummary) memSize() int64 {
+	size := 128 + int64(len(s.RequestID)+len(s.Method)+len(s.Path)+len(s.Query)+len(s.IP))
+	for _, event := range s.Trace {
+		size += 48 + int64(len(event.Stage))
+	}
+	return size
+}
+
+// add stores a summary, dropping the oldest once the buffer is full or the
+// server's buffer memory budget is exceeded
+func (b *requestBuffer) add(summary RequestSummary) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries = append(b.entries, summary)
+	b.grow(summary.memSize())
+	for len(b.entries) > requestBufferSize || (len(b.entries) > 0 && b.budget.exceeded()) {
+		b.grow(-b.entries[0].memSize())
+		b.entries = b.entries[1:]
+	}
+}
+
+// grow accounts n more bytes (or fewer, if negative) to the buffer and the budget
+func (b *requestBuffer) grow(n int64) {
+	b.bytes += n
+	b.budget.add(n)
+}
+
+// release returns the buffer's memory to the budget when it is dropped
+func (b *requestBuffer) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.grow(-b.bytes)
+	b.entries = nil
+}
+
+// snapshot returns up to limit of the most recent summaries, oldest first.
+// A limit of 0 returns everything.
+func (b *requestBuffer) snapshot(limit int) []RequestSummary {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := b.entries
+	if limit > 0 && limit < len(entries) {
+		entries = entries[len(entries)-limit:]
+	}
+	return append([]RequestSummary(nil), entries...)
+}
+
+// memoryBudget tracks the memory held by request and error buffers against
+// server.max_buffer_memory_bytes. A nil budget tracks nothing.
+type memoryBudget struct {
+	limit int64 // 0 = unlimited
+	used  atomic.Int64
+}
+
+func (m *memoryBudget) add(n int64) {
+	if m != nil {
+		m.used.Add(n)
+	}
+}
+
+// exceeded reports whether the buffers hold more than the limit
+func (m *memoryBudget) exceeded() bool {
+	return m != nil && m.limit > 0 && m.used.Load() > m.limit
+}
+
+// requestBuffer returns the webhook's recent request buffer, creating it on first use
+func (ws *WebhookServer) requestBuffer(id string) *requestBuffer {
+	ws.recentMu.Lock()
+	defer ws.recentMu.Unlock()
+
+	buffer, exists := ws.recent[id]
+	if !exists {
+		buffer = &requestBuffer{budget: ws.bufferMemory}
+		ws.recent[id] = buffer
+	}
+	return buffer
+}
+
+// expiryCheckInterval is how often webhooks with a ttl are checked for expiry
+const expiryCheckInterval = 5 * time.Second
+
+// Page sizes for GET /api/webhooks
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// version is the build version, injected with -ldflags "-X main.version=..."
+var version = "dev"
+
+func NewTPSCalculator() *TPSCalculator {
+	return &TPSCalculator{
+		armedAt:       time.Now(),
+		methodCounts:  make(map[string]int64),
+		methodTimes:   make(map[string]*methodLatency),
+		patternHits:   make(map[string]int64),
+		labelCounts:   make(map[string]int64),
+		variantCounts: make(map[string]int64),
+	}
+}
+
+func loadConfigFromYAML(filename string) (*WebhookConfigFile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var config WebhookConfigFile
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// NewWebhookServer creates a server configured from ./config.yaml
+func NewWebhookServer(router *gin.Engine) (*WebhookServer, *WebhookConfigFile) {
+	return NewWebhookServerWithConfig(router, "config.yaml")
+}
+
+// NewWebhookServerWithConfig creates a server configured from the given YAML file,
+// falling back to the built-in default webhooks if it cannot be loaded
+func NewWebhookServerWithConfig(router *gin.Engine, configPath string) (*WebhookServer, *WebhookConfigFile) {
+	config, err := loadConfigFromYAML(configPath)
+	if err != nil {
+		logrus.Warnf("Could not load %s: %v, using default configuration", configPath, err)
+		return NewWebhookServerFromConfig(router, nil)
+	}
+
+	logrus.Infof("Loading webhooks from %s", configPath)
+	return NewWebhookServerFromConfig(router, config)
+}
+
+// NewWebhookServerFromConfig creates a server from an in-memory config.
+// A nil config uses the built-in default webhooks. Defaults and environment
+// overrides are applied to the returned config.
+func NewWebhookServerFromConfig(router *gin.Engine, config *WebhookConfigFile) (*WebhookServer, *WebhookConfigFile) {
+	server := &WebhookServer{
+		webhooks:     make(map[string]*Webhook),
+		routes:       make(map[string]string),
+		histograms:   make(map[string]*latencyHistograms),
+		recent:       make(map[string]*requestBuffer),
+		clients:      make(map[string]*clientTracker),
+		errors:       make(map[string]*errorBuffer),
+		bufferMemory: &memoryBudget{},
+		router:       router,
+		startedAt:    time.Now(),
+		calculator:   NewTPSCalculator(),
+	}
+
+	// Webhook file paths are resolved against files_dir, so it has to be set
+	// before any webhook is compiled
+	server.dirs = webhookDirs{files: defaultFilesDir, captures: defaultCaptureDir}
+	if config != nil && config.Server.FilesDir != "" {
+		server.dirs.files = config.Server.FilesDir
+	}
+	if config != nil && config.Server.CaptureDir != "" {
+		server.dirs.captures = config.Server.CaptureDir
+	}
+
+	if config == nil {
+		// Use default configuration if no config was provided
+		server.loadDefaultWebhooks()
+		config = &WebhookConfigFile{}
+	} else {
+		server.loadWebhooksFromConfig(config)
+	}
+
+	applyEnvOverrides(config)
+
+	// Set defaults if not specified
+	if config.Server.Port == 0 {
+		config.Server.Port = 8080
+	}
+	if config.Server.Host == "" {
+		config.Server.Host = "localhost"
+	}
+	applyServerTimeoutDefaults(config)
+	server.maxWebhooks = config.Server.MaxWebhooks
+	if config.Server.MaxConnections < 0 {
+		logrus.Warnf("Ignoring negative max_connections %d", config.Server.MaxConnections)
+		config.Server.MaxConnections = 0
+	}
+	if config.Server.MaxBufferMemoryBytes < 0 {
+		logrus.Warnf("Ignoring negative max_buffer_memory_bytes %d", config.Server.MaxBufferMemoryBytes)
+		config.Server.MaxBufferMemoryBytes = 0
+	}
+	server.bufferMemory.limit = config.Server.MaxBufferMemoryBytes
+	applyHealthDefaults(config)
+	server.healthWindow = config.Health.Window
+	server.degradedErrorRate = config.Health.DegradedErrorRate
+	server.latencyBuckets = latencyBucketsOrDefault(config.Prometheus.LatencyBuckets)
+	config.Prometheus.LatencyBuckets = server.latencyBuckets
+	server.percentiles = percentilesOrDefault(config.Metrics.Percentiles)
+	config.Metrics.Percentiles = server.percentiles
+	server.tpsPrecision = tpsPrecisionOrDefault(config.Metrics.TPSPrecision)
+	precision := server.tpsPrecision
+	config.Metrics.TPSPrecision = &precision
+	if config.Server.MaxEffectiveDelayStatus == 0 {
+		config.Server.MaxEffectiveDelayStatus = http.StatusAccepted
+	}
+	server.maxEffectiveDelay = config.Server.MaxEffectiveDelay
+	if config.Server.StartupDelay < 0 {
+		logrus.Warnf("Ignoring negative startup_delay %s", config.Server.StartupDelay)
+		config.Server.StartupDelay = 0
+	}
+	server.startup = newStartupGate(server.startedAt, config.Server.StartupDelay)
+	server.startupBlocksWebhooks = config.Server.StartupBlockWebhooks
+	server.skippedDelayStatus = config.Server.MaxEffectiveDelayStatus
+	if config.Server.CatchAll {
+		server.ensureCatchAllWebhook()
+	}
+	server.config = config
+	return server, config
+}
+
+// applyHealthDefaults fills in and sanitizes the health thresholds
+func applyHealthDefaults(config *WebhookConfigFile) {
+	window := config.Health.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+	if window < time.Second {
+		window = time.Second
+	}
+	if maxWindow := bucketRetentionSeconds * time.Second; window > maxWindow {
+		logrus.Warnf("health.window %s exceeds the %s retention, using %s", window, maxWindow, maxWindow)
+		window = maxWindow
+	}
+	config.Health.Window = window
+
+	rate := config.Health.DegradedErrorRate
+	if rate < 0 || rate > 1 {
+		logrus.Warnf("Ignoring invalid health.degraded_error_rate %v, using 0.1", rate)
+		rate = 0
+	}
+	if rate == 0 {
+		rate = 0.1
+	}
+	config.Health.DegradedErrorRate = rate
+}
+
+// webhookHealth classifies a webhook from its recent activity: idle without
+// requests in the window, degraded when the 5xx rate exceeds the threshold
+func (ws *WebhookServer) webhookHealth(webhook *Webhook) gin.H {
+	windowSeconds := int(ws.healthWindow / time.Second)
+	requests, serverErrors := webhook.Calculator.RecentActivity(windowSeconds)
+
+	status := "healthy"
+	var errorRate float64
+	if requests == 0 {
+		status = "idle"
+	} else {
+		errorRate = float64(serverErrors) / float64(requests)
+		if errorRate > ws.degradedErrorRate {
+			status = "degraded"
+		}
+	}
+	return gin.H{
+		"webhook_id":     webhook.ID,
+		"status":         status,
+		"window_seconds": windowSeconds,
+		"requests":       requests,
+		"server_errors":  serverErrors,
+		"error_rate":     errorRate,
+	}
+}
+
+// applyEnvOverrides overrides config values from environment variables.
+// Precedence is env > yaml > default; invalid values are ignored with a warning.
+func applyEnvOverrides(config *WebhookConfigFile) {
+	if value := os.Getenv("WEBHOOK_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			logrus.Warnf("Ignoring invalid WEBHOOK_PORT %q", value)
+		} else {
+			config.Server.Port = port
+		}
+	}
+	if value := os.Getenv("WEBHOOK_HOST"); value != "" {
+		config.Server.Host = value
+	}
+	if value := os.Getenv("WEBHOOK_LOG_LEVEL"); value != "" {
+		if _, err := logrus.ParseLevel(value); err != nil {
+			logrus.Warnf("Ignoring invalid WEBHOOK_LOG_LEVEL %q", value)
+		} else {
+			config.Logging.LogLevel = value
+		}
+	}
+	if value := os.Getenv("WEBHOOK_LOG_FORMAT"); value != "" {
+		if value != "text" && value != "json" {
+			logrus.Warnf("Ignoring invalid WEBHOOK_LOG_FORMAT %q (use text or json)", value)
+		} else {
+			config.Logging.LogFormat = value
+		}
+	}
+}
+
+// defaultLogFile is the log file when logging.log_file is not set
+const defaultLogFile = "webhook.log"
+
+// newLogFile returns the rotating log file described by the logging config
+func newLogFile(config *WebhookConfigFile) *lumberjack.Logger {
+	filename := config.Logging.LogFile
+	if filename == "" {
+		filename = defaultLogFile
+	}
+	maxSize := config.Logging.MaxSizeMB
+	if maxSize <= 0 {
+		maxSize = 100
+	}
+	return &lumberjack.Logger{
+		Filename:   filename,
+		MaxSize:    maxSize,
+		MaxBackups: config.Logging.MaxBackups,
+		MaxAge:     config.Logging.MaxAgeDays,
+		Compress:   config.Logging.Compress,
+	}
+}
+
+// applyLoggingConfig sets the log level and format and starts file output to
+// the configured rotating log file, beginning with the lines logged before
+// the config was loaded. It returns the file now in use.
+func applyLoggingConfig(config *WebhookConfigFile, startupLog *bytes.Buffer) *lumberjack.Logger {
+	logFile := newLogFile(config)
+	if _, err := logFile.Write(startupLog.Bytes()); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write log file %s: %v\n", logFile.Filename, err)
+	}
+	logrus.SetOutput(io.MultiWriter(os.Stdout, logFile))
+	if config.Logging.LogLevel != "" {
+		level, err := logrus.ParseLevel(config.Logging.LogLevel)
+		if err != nil {
+			logrus.Warnf("Ignoring invalid log_level %q", config.Logging.LogLevel)
+		} else {
+			logrus.SetLevel(level)
+		}
+	}
+	if config.Logging.LogFormat == "json" {
+		logrus.SetFormatter(&logrus.JSONFormatter{
+			TimestampFormat: "2006-01-02 15:04:05",
+		})
+	}
+	return logFile
+}
+
+// applyServerTimeoutDefaults fills in http.Server timeouts that were not configured.
+// The write timeout must stay above the longest webhook delay or slow responses get cut off.
+func applyServerTimeoutDefaults(config *WebhookConfigFile) {
+	if config.Server.ReadTimeout == 0 {
+		config.Server.ReadTimeout = 30 * time.Second
+	}
+	if config.Server.WriteTimeout == 0 {
+		config.Server.WriteTimeout = 60 * time.Second
+	}
+	if config.Server.IdleTimeout == 0 {
+		config.Server.IdleTimeout = 120 * time.Second
+	}
+	if config.Server.ReadHeaderTimeout == 0 {
+		config.Server.ReadHeaderTimeout = 10 * time.Second
+	}
+}
+
+func (ws *WebhookServer) loadDefaultWebhooks() {
+	// Create default webhooks (fallback)
+	defaultWebhook := &Webhook{
+		ID:   "default",
+		Name: "Default Webhook",
+		Path: "/webhook",
+		Config: WebhookConfig{
+			StatusCode:    200,
+			ContentType:   "application/json",
+			ResponseBody:  `{"message": "Request received"}`,
+			Timeout:       0,
+			Headers:       make(map[string]string),
+			EnableLogging: true,
+		},
+		Calculator: NewTPSCalculator(),
+		CreatedAt:  time.Now(),
+		Builtin:    true,
+	}
+
+	fastWebhook := &Webhook{
+		ID:   "fast",
+		Name: "Fast Webhook",
+		Path: "/webhook/fast",
+		Config: WebhookConfig{
+			StatusCode:    200,
+			ContentType:   "application/json",
+			ResponseBody:  `{"message": "Fast response", "delay": "0ms"}`,
+			Timeout:       0,
+			Headers:       make(map[string]string),
+			EnableLogging: false,
+		},
+		Calculator: NewTPSCalculator(),
+		CreatedAt:  time.Now(),
+		Builtin:    true,
+	}
+
+	slowWebhook := &Webhook{
+		ID:   "slow",
+		Name: "Slow Webhook",
+		Path: "/webhook/slow",
+		Config: WebhookConfig{
+			StatusCode:    200,
+			ContentType:   "application/json",
+			ResponseBody:  `{"message": "Slow response", "delay": "2000ms"}`,
+			Timeout:       2000,
+			Headers:       make(map[string]string),
+			EnableLogging: true,
+		},
+		Calculator: NewTPSCalculator(),
+		CreatedAt:  time.Now(),
+		Builtin:    true,
+	}
+
+	ws.webhooks["default"] = defaultWebhook
+	ws.webhooks["fast"] = fastWebhook
+	ws.webhooks["slow"] = slowWebhook
+}
+
+func (ws *WebhookServer) loadWebhooksFromConfig(config *WebhookConfigFile) {
+	for _, webhookConfig := range config.DefaultWebhooks {
+		if webhookConfig.Config.Headers == nil {
+			webhookConfig.Config.Headers = make(map[string]string)
+		}
+		
+		webhook := &Webhook{
+			ID:         webhookConfig.ID,
+			Name:       webhookConfig.Name,
+			Path:       webhookConfig.Path,
+			Tags:       webhookConfig.Tags,
+			Config:     webhookConfig.Config,
+			Calculator: NewTPSCalculator(),
+			CreatedAt:  time.Now(),
+			Builtin:    true,
+		}
+
+		if webhook.ID != catchAllWebhookID {
+			// Normalize paths the same way createWebhook does, telling the user about it
+			if webhook.Path == "" {
+				webhook.Path = "/w/" + webhook.ID
+				logrus.Warnf("Webhook %q has no path, using %s", webhookConfig.ID, webhook.Path)
+			} else if !strings.HasPrefix(webhook.Path, "/") {
+				webhook.Path = "/" + webhook.Path
+				logrus.Warnf("Webhook %q path %q does not start with /, using %s", webhookConfig.ID, webhookConfig.Path, webhook.Path)
+			}
+			if err := validateWebhookPath(webhook.Path); err != nil {
+				logrus.Errorf("Skipping webhook %q: %v", webhookConfig.ID, err)
+				continue
+			}
+			if err := ws.checkRouteLocked(webhook.Path); err != nil {
+				logrus.Errorf("Skipping webhook %q: %v", webhookConfig.ID, err)
+				continue
+			}
+		}
+
+		if err := webhook.compileConfig(ws.dirs); err != nil {
+			logrus.Errorf("Skipping webhook %q: %v", webhookConfig.ID, err)
+			continue
+		}
+		
+		ws.webhooks[webhookConfig.ID] = webhook
+
+		// The catch-all webhook is served by the NoRoute handler, not by its own path
+		if webhook.ID == catchAllWebhookID {
+			webhook.Path = "*"
+			continue
+		}
+
+		// Register route for this webhook
+		ws.registerWebhookRoute(webhook)
+	}
+
+	// Fan-out targets may be defined later in the file, so check them once all
+	// are loaded; skipping a webhook can invalidate another that targets it
+	for skipped := true; skipped; {
+		skipped = false
+		for _, id := range sortedKeys(ws.webhooks) {
+			webhook := ws.webhooks[id]
+			if err := ws.checkFanOutLocked(webhook); err != nil {
+				logrus.Errorf("Skipping webhook %q: %v", id, err)
+				ws.removeWebhookLocked(webhook)
+				skipped = true
+			}
+		}
+	}
+}
+
+// exportConfig snapshots the server settings and all current webhooks as a
+// config file that the loader turns back into the same webhooks. Runtime
+// state (metrics, last request, expiry) is not included.
+func (ws *WebhookServer) exportConfig() WebhookConfigFile {
+	exported := *ws.config
+	exported.DefaultWebhooks = nil
+
+	webhooks := ws.getAllWebhooks()
+	sort.Slice(webhooks, func(i, j int) bool {
+		if !webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+		}
+		return webhooks[i].ID < webhooks[j].ID
+	})
+
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	for _, webhook := range webhooks {
+		exported.DefaultWebhooks = append(exported.DefaultWebhooks, WebhookConfigEntry{
+			ID:     webhook.ID,
+			Name:   webhook.Name,
+			Path:   webhook.Path,
+			Tags:   webhook.Tags,
+			Config: webhook.Config,
+		})
+	}
+	return exported
+}
+
+// importConfig creates or updates webhooks to match a config file. Every entry
+// is validated before anything changes. In replace mode, webhooks missing from
+// the import are removed, except built-ins (webhooks loaded at startup).
+// Server settings are not applied.
+func (ws *WebhookServer) importConfig(config *WebhookConfigFile, replace bool) (gin.H, error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	imported := make(map[string]bool)
+	paths := make(map[string]string) // path -> ID of the imported webhook claiming it
+	for i := range config.DefaultWebhooks {
+		entry := &config.DefaultWebhooks[i]
+		if entry.ID == "" {
+			return nil, fmt.Errorf("webhook %d: id is required", i)
+		}
+		if imported[entry.ID] {
+			return nil, fmt.Errorf("webhook %s: duplicate id", entry.ID)
+		}
+		imported[entry.ID] = true
+		if entry.Config.Headers == nil {
+			entry.Config.Headers = make(map[string]string)
+		}
+
+		if entry.ID == catchAllWebhookID {
+			entry.Path = "*"
+		} else {
+			if entry.Path == "" {
+				entry.Path = "/w/" + entry.ID
+			} else if !strings.HasPrefix(entry.Path, "/") {
+				entry.Path = "/" + entry.Path
+			}
+			if existing, exists := ws.webhooks[entry.ID]; exists && existing.Builtin && existing.Path != entry.Path {
+				return nil, withCode(codePathConflict, fmt.Errorf("webhook %s: the path of a built-in webhook can't be changed", entry.ID))
+			}
+			if err := validateWebhookPath(entry.Path); err != nil {
+				return nil, fmt.Errorf("webhook %s: %w", entry.ID, err)
+			}
+			if other, claimed := paths[entry.Path]; claimed {
+				return nil, withCode(codePathConflict, fmt.Errorf("webhook %s: path %s is also used by webhook %s", entry.ID, entry.Path, other))
+			}
+			if err := ws.checkRouteLocked(entry.Path); err != nil {
+				return nil, fmt.Errorf("webhook %s: %w", entry.ID, err)
+			}
+			for other := range paths {
+				if routesConflict(entry.Path, other) {
+					return nil, withCode(codePathConflict, fmt.Errorf("webhook %s: path %s conflicts with path %s of webhook %s", entry.ID, entry.Path, other, paths[other]))
+				}
+			}
+			paths[entry.Path] = entry.ID
+		}
+
+		// Compile on a scratch webhook so invalid configs are caught before applying
+		scratch := &Webhook{ID: entry.ID, Config: entry.Config}
+		if err := scratch.compileConfig(ws.dirs); err != nil {
+			return nil, fmt.Errorf("webhook %s: %w", entry.ID, err)
+		}
+		if scratch.capture != nil {
+			scratch.capture.Close()
+		}
+	}
+
+	// Webhooks that stay keep their paths, so the import can't claim them
+	remaining := len(imported)
+	for id, existing := range ws.webhooks {
+		if imported[id] || (replace && !existing.Builtin) {
+			continue
+		}
+		remaining++
+		if other, claimed := paths[existing.Path]; claimed {
+			return nil, withCode(codePathConflict, fmt.Errorf("webhook %s: path %s is already used by webhook %s", other, existing.Path, id))
+		}
+	}
+	if ws.maxWebhooks > 0 && remaining > ws.maxWebhooks {
+		return nil, withCode(codeWebhookLimit, fmt.Errorf("import would result in %d webhooks, above the limit of %d", remaining, ws.maxWebhooks))
+	}
+
+	// Fan-out targets must be imported or stay after the import
+	kept := func(id string) bool {
+		if imported[id] {
+			return true
+		}
+		existing, exists := ws.webhooks[id]
+		return exists && (!replace || existing.Builtin)
+	}
+	for _, entry := range config.DefaultWebhooks {
+		if err := checkFanOut(entry.ID, entry.Config.FanOutTo, kept); err != nil {
+			return nil, withCode(codeInvalidConfig, fmt.Errorf("webhook %s: %w", entry.ID, err))
+		}
+	}
+
+	created, updated, removed := []string{}, []string{}, []string{}
+	if replace {
+		for id, existing := range ws.webhooks {
+			if !imported[id] && !existing.Builtin {
+				ws.removeWebhookLocked(existing)
+				removed = append(removed, id)
+			}
+		}
+	}
+
+	for _, entry := range config.DefaultWebhooks {
+		webhook, exists := ws.webhooks[entry.ID]
+		if !exists {
+			webhook = &Webhook{
+				ID:         entry.ID,
+				Calculator: NewTPSCalculator(),
+				CreatedAt:  time.Now(),
+			}
+		}
+		webhook.Name = entry.Name
+		webhook.Tags = entry.Tags
+		webhook.Config = entry.Config
+		if err := webhook.compileConfig(ws.dirs); err != nil {
+			logrus.Errorf("Imported webhook %q failed to compile after validation: %v", entry.ID, err)
+		}
+
+		if exists {
+			if entry.ID != catchAllWebhookID && webhook.Path != entry.Path {
+				if ws.routes[webhook.Path] == webhook.ID {
+					ws.routes[webhook.Path] = ""
+				}
+				webhook.Path = entry.Path
+				ws.registerWebhookRoute(webhook)
+			}
+			updated = append(updated, entry.ID)
+			continue
+		}
+
+		webhook.Path = entry.Path
+		ws.webhooks[entry.ID] = webhook
+		if entry.ID != catchAllWebhookID {
+			ws.registerWebhookRoute(webhook)
+		}
+		created = append(created, entry.ID)
+	}
+
+	sort.Strings(created)
+	sort.Strings(updated)
+	sort.Strings(removed)
+	return gin.H{
+		"created": created,
+		"updated": updated,
+		"removed": removed,
+	}, nil
+}
+
+// ensureCatchAllWebhook creates a default catch-all webhook if the config enables
+// catch-all routing without defining a "catchall" entry
+func (ws *WebhookServer) ensureCatchAllWebhook() {
+	if _, exists := ws.webhooks[catchAllWebhookID]; exists {
+		return
+	}
+
+	ws.webhooks[catchAllWebhookID] = &Webhook{
+		ID:   catchAllWebhookID,
+		Name: "Catch-All Webhook",
+		Path: "*",
+		Config: WebhookConfig{
+			StatusCode:    http.StatusNotFound,
+			ContentType:   "application/json",
+			ResponseBody:  `{"error": "Route not found"}`,
+			Timeout:       0,
+			Headers:       make(map[string]string),
+			EnableLogging: true,
+		},
+		Calculator: NewTPSCalculator(),
+		CreatedAt:  time.Now(),
+		Builtin:    true,
+	}
+}
+
+// selfTestResult is the outcome of one startup self-test request
+type selfTestResult struct {
+	status  int
+	skipped string // reason the webhook was not tested
+}
+
+// runSelfTest sends a synthetic request to every webhook in-process and logs
+// whether the configured status came back. It returns the number of failures.
+func (ws *WebhookServer) runSelfTest() int {
+	webhooks := ws.getAllWebhooks()
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+
+	failed := 0
+	for _, webhook := range webhooks {
+		result := ws.selfTestWebhook(webhook)
+		fields := logrus.Fields{
+			"webhook_id": webhook.ID,
+			"path":       webhook.Path,
+			"expected":   webhook.Config.StatusCode,
+		}
+		switch {
+		case result.skipped != "":
+			fields["reason"] = result.skipped
+			logrus.WithFields(fields).Info("Self-test skipped")
+		case result.status == webhook.Config.StatusCode:
+			fields["status"] = result.status
+			logrus.WithFields(fields).Info("Self-test passed")
+		default:
+			fields["status"] = result.status
+			logrus.WithFields(fields).Error("Self-test failed")
+			failed++
+		}
+	}
+	return failed
+}
+
+// selfTestWebhook runs the real handler against a copy of the webhook on a
+// scratch server, so metrics, histograms and request buffers stay untouched.
+// Delays are dropped and side effects (body capture) are turned off.
+func (ws *WebhookServer) selfTestWebhook(webhook *Webhook) selfTestResult {
+	if webhook.Config.ForwardTo != "" {
+		return selfTestResult{skipped: "forward_to proxies to an upstream"}
+	}
+	if webhook.Config.RequestSchema != "" || webhook.Config.RequestSchemaFile != "" {
+		return selfTestResult{skipped: "request_schema needs a real payload"}
+	}
+
+	ws.mu.RLock()
+	probe := *webhook
+	probe.Config.Headers = cloneHeaders(webhook.Config.Headers)
+	ws.mu.RUnlock()
+	probe.Calculator = NewTPSCalculator()
+	probe.capture = nil
+	probe.limiter = nil
+	probe.compiled = nil // fresh trackers, so the probe leaves the webhook's state alone
+	probe.Config.CaptureBodiesTo = ""
+	probe.Config.Timeout = 0
+	probe.Config.MethodTimeouts = nil
+	probe.Config.DelayDistribution = nil
+	probe.Config.Backoff = nil
+	probe.Config.LingerMs = 0
+	probe.Config.WriteDelayPerChunk = 0
+	probe.Config.ConcurrencyLatencyFactor = 0
+	probe.Config.DelayPattern = nil
+	probe.Config.DelayPerKB = 0
+	probe.Config.ColdStart = nil
+	probe.Config.CircuitBreaker = nil
+	probe.Config.Sequence = nil
+	probe.Config.MaxConcurrency = 0
+	probe.Config.EnableLogging = false
+	probe.Config.MinLatencyMs = 0
+	probe.Config.StartupDelayMs = 0
+	probe.Config.FailFirstN = 0
+	if err := probe.compileConfig(ws.dirs); err != nil {
+		return selfTestResult{skipped: err.Error()}
+	}
+
+	scratch := &WebhookServer{
+		webhooks:       map[string]*Webhook{probe.ID: &probe},
+		routes:         make(map[string]string),
+		histograms:     make(map[string]*latencyHistograms),
+		recent:         make(map[string]*requestBuffer),
+		clients:        make(map[string]*clientTracker),
+		errors:         make(map[string]*errorBuffer),
+		calculator:     NewTPSCalculator(),
+		latencyBuckets: ws.latencyBuckets,
+	}
+
+	body := []byte("{}")
+	path := probe.Path
+	if probe.ID == catchAllWebhookID || path == "" {
+		path = "/w/" + probe.ID
+	}
+	request := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	for _, name := range probe.Config.RequiredHeaders {
+		request.Header.Set(name, "self-test")
+	}
+	if signature := probe.signature; signature != nil {
+		request.Header.Set(signature.Header, signature.sign(body))
+	}
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = request
+	scratch.handleWebhookRequest(probe.ID, c)
+	c.Writer.WriteHeaderNow()
+	return selfTestResult{status: recorder.Code}
+}
+
+// redactConfig returns a copy of the config with secrets masked, for
+// endpoints that echo configs alongside metrics
+func redactConfig(config WebhookConfig) WebhookConfig {
+	if config.SignatureVerification != nil {
+		signature := *config.SignatureVerification
+		signature.Secret = redactedHeaderValue
+		config.SignatureVerification = &signature
+	}
+	if config.ResponseSigning != nil {
+		signing := *config.ResponseSigning
+		signing.Secret = redactedHeaderValue
+		config.ResponseSigning = &signing
+	}
+	return config
+}
+
+// hasTag reports whether the webhook carries the tag
+func (w *Webhook) hasTag(tag string) bool {
+	for _, t := range w.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// AgeSeconds returns how long the webhook has existed
+func (w *Webhook) AgeSeconds() float64 {
+	return time.Since(w.CreatedAt).Seconds()
+}
+
+// MarshalJSON adds computed fields to the webhook JSON
+func (w *Webhook) MarshalJSON() ([]byte, error) {
+	type webhookJSON Webhook
+	return json.Marshal(struct {
+		*webhookJSON
+		AgeSeconds float64 `json:"age_seconds"`
+	}{
+		webhookJSON: (*webhookJSON)(w),
+		AgeSeconds:  w.AgeSeconds(),
+	})
+}
+
+// compileConfig validates the webhook config and prepares runtime state derived
+// from it (e.g. the compiled request schema). It must be called whenever Config changes.
+// File paths in the config are resolved against dirs.
+func (w *Webhook) compileConfig(dirs webhookDirs) error {
+	if w.Config.LogSampleRate < 0 || w.Config.LogSampleRate > 1 {
+		return fmt.Errorf("log_sample_rate must be between 0.0 and 1.0, got %v", w.Config.LogSampleRate)
+	}
+
+	// Method names are matched case-insensitively
+	if w.Config.MethodTimeouts != nil {
+		methodTimeouts := make(map[string]int, len(w.Config.MethodTimeouts))
+		for method, timeout := range w.Config.MethodTimeouts {
+			if timeout < 0 {
+				return fmt.Errorf("method_timeouts[%s] must not be negative", method)
+			}
+			methodTimeouts[strings.ToUpper(method)] = timeout
+		}
+		w.Config.MethodTimeouts = methodTimeouts
+	}
+
+	schema, err := compileRequestSchema(w.Config, dirs.files)
+	if err != nil {
+		return err
+	}
+
+	backoff, err := newIPBackoffTracker(w.Config.Backoff)
+	if err != nil {
+		return err
+	}
+
+	if w.Config.DelayDistribution != nil {
+		if err := w.Config.DelayDistribution.validate(); err != nil {
+			return err
+		}
+	}
+
+	if w.Config.DelayPerKB < 0 || w.Config.MaxBodyDelayMs < 0 {
+		return fmt.Errorf("delay_per_kb and max_body_delay_ms must not be negative")
+	}
+
+	if w.Config.ConcurrencyLatencyFactor < 0 {
+		return fmt.Errorf("concurrency_latency_factor must not be negative, got %v", w.Config.ConcurrencyLatencyFactor)
+	}
+
+	if w.Config.MaxConcurrency < 0 || w.Config.MaxQueue < 0 {
+		return fmt.Errorf("max_concurrency and max_queue must not be negative")
+	}
+
+	if w.Config.ForwardTo != "" {
+		upstream, err := url.Parse(w.Config.ForwardTo)
+		if err != nil || (upstream.Scheme != "http" && upstream.Scheme != "https") || upstream.Host == "" {
+			return fmt.Errorf("forward_to must be an absolute http(s) URL, got %q", w.Config.ForwardTo)
+		}
+	}
+
+	headerLog, err := newHeaderLogFilter(w.Config)
+	if err != nil {
+		return err
+	}
+
+	var logBodyPaths []jsonPath
+	for _, raw := range w.Config.LogBodyJSONPaths {
+		path, err := parseJSONPath(raw)
+		if err != nil {
+			return fmt.Errorf("log_body_json_paths: %w", err)
+		}
+		logBodyPaths = append(logBodyPaths, path)
+	}
+
+	var metricsLabel *jsonPath
+	if w.Config.MetricsLabelJSONPath != "" {
+		path, err := parseJSONPath(w.Config.MetricsLabelJSONPath)
+		if err != nil {
+			return fmt.Errorf("metrics_label_json_path: %w", err)
+		}
+		metricsLabel = &path
+	}
+	if w.Config.MetricsLabelMaxValues < 0 {
+		return fmt.Errorf("metrics_label_max_values must not be negative, got %d", w.Config.MetricsLabelMaxValues)
+	}
+
+	repeat, err := newRepeatTracker(w.Config.FailOnRepeat)
+	if err != nil {
+		return err
+	}
+
+	delayPattern, err := newDelayPattern(w.Config.DelayPattern)
+	if err != nil {
+		return err
+	}
+
+	coldStart, err := newColdStartTracker(w.Config.ColdStart)
+	if err != nil {
+		return err
+	}
+
+	circuit, err := newCircuitBreaker(w.Config.CircuitBreaker)
+	if err != nil {
+		return err
+	}
+
+	sequence, err := newResponseSequence(w.Config.Sequence)
+	if err != nil {
+		return err
+	}
+
+	var rotation *bodyRotation
+	if len(w.Config.ResponseBodyList) > 0 {
+		for i, entry := range w.Config.ResponseBodyList {
+			if entry.StatusCode != 0 && (entry.StatusCode < 100 || entry.StatusCode > 599) {
+				return fmt.Errorf("response_body_list[%d] status_code %d is not a valid HTTP status", i, entry.StatusCode)
+			}
+		}
+		rotation = &bodyRotation{}
+	}
+
+	split, err := newABSplit(w.Config.ABSplit)
+	if err != nil {
+		return err
+	}
+
+	if len(w.Config.QueryResponseMap) > 0 && w.Config.QueryResponseParam == "" {
+		return fmt.Errorf("query_response_map needs query_response_param")
+	}
+	if w.Config.QueryResponseParam != "" && len(w.Config.QueryResponseMap) == 0 {
+		return fmt.Errorf("query_response_param needs a query_response_map")
+	}
+
+	if w.Config.DeadlineBudgetMs < 0 {
+		return fmt.Errorf("deadline_budget_ms must not be negative, got %d", w.Config.DeadlineBudgetMs)
+	}
+	if w.Config.MinLatencyMs < 0 {
+		return fmt.Errorf("min_latency_ms must not be negative, got %d", w.Config.MinLatencyMs)
+	}
+	if w.Config.MaxURILength < 0 {
+		return fmt.Errorf("max_uri_length must not be negative, got %d", w.Config.MaxURILength)
+	}
+	if w.Config.FailFirstN < 0 {
+		return fmt.Errorf("fail_first_n must not be negative, got %d", w.Config.FailFirstN)
+	}
+	if w.Config.StartupDelayMs < 0 {
+		return fmt.Errorf("startup_delay_ms must not be negative, got %d", w.Config.StartupDelayMs)
+	}
+
+	for _, name := range w.Config.RequiredHeaders {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("required_headers must not contain empty names")
+		}
+	}
+	if status := w.Config.RequiredHeadersStatus; status != 0 && (status < 400 || status > 599) {
+		return fmt.Errorf("required_headers_status must be a 4xx or 5xx status, got %d", status)
+	}
+
+	var signature *SignatureVerification
+	if w.Config.SignatureVerification != nil {
+		effective, err := w.Config.SignatureVerification.withDefaults("signature_verification", "X-Signature")
+		if err != nil {
+			return err
+		}
+		signature = &effective
+	}
+
+	var responseSigning *SignatureVerification
+	if w.Config.ResponseSigning != nil {
+		effective, err := w.Config.ResponseSigning.withDefaults("response_signing", "X-Response-Signature")
+		if err != nil {
+			return err
+		}
+		responseSigning = &effective
+	}
+
+	// Inline templates are parsed once here; file templates are parsed per request
+	var bodyTemplate *template.Template
+	if w.Config.ResponseTemplate && w.Config.ResponseBodyFile == "" {
+		bodyTemplate, err = template.New(w.ID).Option("missingkey=error").Parse(w.Config.ResponseBody)
+		if err != nil {
+			return fmt.Errorf("invalid response_body template: %w", err)
+		}
+	}
+
+	var bodyFilePath string
+	if w.Config.ResponseBodyFile != "" {
+		bodyFilePath, err = resolveUnder(dirs.files, "response_body_file", w.Config.ResponseBodyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	var capturePath string
+	if w.Config.CaptureBodiesTo != "" {
+		capturePath, err = resolveUnder(dirs.captures, "capture_bodies_to", w.Config.CaptureBodiesTo)
+		if err != nil {
+			return err
+		}
+	}
+
+	random := newWebhookRand(w.Config.RandomSeed)
+	startup := newStartupGate(w.CreatedAt, time.Duration(w.Config.StartupDelayMs)*time.Millisecond)
+
+	// Trackers whose config section is unchanged keep their state (per-IP
+	// backoff, sequence position, circuit state, ...) across config updates
+	if previous := w.compiled; previous != nil {
+		if reflect.DeepEqual(previous.Backoff, w.Config.Backoff) {
+			backoff = w.backoff
+		}
+		if reflect.DeepEqual(previous.FailOnRepeat, w.Config.FailOnRepeat) {
+			repeat = w.repeat
+		}
+		if reflect.DeepEqual(previous.DelayPattern, w.Config.DelayPattern) {
+			delayPattern = w.delayPattern
+		}
+		if reflect.DeepEqual(previous.ColdStart, w.Config.ColdStart) {
+			coldStart = w.coldStart
+		}
+		if reflect.DeepEqual(previous.CircuitBreaker, w.Config.CircuitBreaker) {
+			circuit = w.circuit
+		}
+		if reflect.DeepEqual(previous.Sequence, w.Config.Sequence) {
+			sequence = w.sequence
+		}
+		if rotation != nil && reflect.DeepEqual(previous.ResponseBodyList, w.Config.ResponseBodyList) {
+			rotation = w.bodyRotation
+		}
+		if previous.RandomSeed == w.Config.RandomSeed {
+			random = w.rand
+		}
+		if previous.StartupDelayMs == w.Config.StartupDelayMs {
+			startup = w.startup
+		}
+	}
+
+	w.requestSchema = schema
+	w.backoff = backoff
+	w.capture = updateBodyCapture(w.capture, capturePath, w.Config.CaptureMaxSizeMB)
+	w.bodyTemplate = bodyTemplate
+	w.bodyFilePath = bodyFilePath
+	w.headerLog = headerLog
+	w.logBodyPaths = logBodyPaths
+	w.metricsLabel = metricsLabel
+	w.signature = signature
+	w.responseSigning = responseSigning
+	w.repeat = repeat
+	w.delayPattern = delayPattern
+	w.coldStart = coldStart
+	w.circuit = circuit
+	w.sequence = sequence
+	w.bodyRotation = rotation
+	w.abSplit = split
+	w.rand = random
+	w.startup = startup
+	w.limiter = updateConcurrencyLimiter(w.limiter, w.Config)
+	w.etag = ""
+	if w.Config.ETag && w.Config.ResponseBodyFile == "" && !w.Config.ResponseTemplate && sequence == nil && len(w.Config.QueryResponseMap) == 0 && rotation == nil && split == nil {
+		w.etag = bodyETag(w.Config.ResponseBody)
+	}
+	compiled := w.Config
+	w.compiled = &compiled
+	return nil
+}
+
+// bodyETag returns a strong ETag for a response body
+func bodyETag(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag.
+// Weak comparison is used, as RFC 9110 requires for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// errQueueFull is returned by concurrencyLimiter.acquire when the wait queue is at capacity
+var errQueueFull = errors.New("request queue is full")
+
+// concurrencyLimiter is a semaphore bounding in-flight requests, with a
+// bounded number of requests allowed to wait for a slot
+type concurrencyLimiter struct {
+	slots    chan struct{}
+	maxQueue int64 // 0 = unbounded
+	queued   atomic.Int64
+}
+
+// updateConcurrencyLimiter keeps the current limiter when its limits are
+// unchanged, otherwise builds a new one. In-flight requests release the limiter
+// they acquired, so swapping it is safe.
+func updateConcurrencyLimiter(current *concurrencyLimiter, config WebhookConfig) *concurrencyLimiter {
+	if config.MaxConcurrency <= 0 {
+		return nil
+	}
+	if current != nil && cap(current.slots) == config.MaxConcurrency && current.maxQueue == int64(config.MaxQueue) {
+		return current
+	}
+	return &concurrencyLimiter{
+		slots:    make(chan struct{}, config.MaxConcurrency),
+		maxQueue: int64(config.MaxQueue),
+	}
+}
+
+// acquire takes a slot, waiting in the queue if none is free. It returns how
+// long the request waited, errQueueFull, or the context's error.
+func (l *concurrencyLimiter) acquire(ctx context.Context) (time.Duration, error) {
+	select {
+	case l.slots <- struct{}{}:
+		return 0, nil
+	default:
+	}
+
+	if queued := l.queued.Add(1); l.maxQueue > 0 && queued > l.maxQueue {
+		l.queued.Add(-1)
+		return 0, errQueueFull
+	}
+	defer l.queued.Add(-1)
+
+	start := time.Now()
+	select {
+	case l.slots <- struct{}{}:
+		return time.Since(start), nil
+	case <-ctx.Done():
+		return time.Since(start), ctx.Err()
+	}
+}
+
+// release frees a slot taken by acquire
+func (l *concurrencyLimiter) release() {
+	<-l.slots
+}
+
+// renderResponseBody produces the configured response body for a request,
+// reading it from file and/or executing it as a template
+func (w *Webhook) renderResponseBody(c *gin.Context) (string, error) {
+	body := w.Config.ResponseBody
+	bodyTemplate := w.bodyTemplate
+
+	if w.bodyFilePath != "" {
+		data, err := os.ReadFile(w.bodyFilePath)
+		if err != nil {
+			return "", fmt.Errorf("reading response_body_file: %w", err)
+		}
+		body = string(data)
+
+		if w.Config.ResponseTemplate {
+			bodyTemplate, err = template.New(w.ID).Option("missingkey=error").Parse(body)
+			if err != nil {
+				return "", fmt.Errorf("parsing response_body_file template: %w", err)
+			}
+		}
+	}
+
+	if bodyTemplate == nil {
+		return body, nil
+	}
+
+	requestBody, _ := peekRequestBody(c)
+	var rendered strings.Builder
+	err := bodyTemplate.Execute(&rendered, templateData{
+		Method: c.Request.Method,
+		Path:   c.Request.URL.Path,
+		Body:   string(requestBody),
+		ctx:    c,
+	})
+	if err != nil {
+		return "", fmt.Errorf("executing response template: %w", err)
+	}
+	return rendered.String(), nil
+}
+
+// bodyCapture is a webhook's body capture file. Requests still being served
+// from a snapshot may write to it after an update or delete closed it; those
+// writes are dropped instead of reopening the file.
+type bodyCapture struct {
+	mu     sync.Mutex
+	file   *lumberjack.Logger
+	closed bool
+}
+
+func (b *bodyCapture) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return len(p), nil
+	}
+	return b.file.Write(p)
+}
+
+func (b *bodyCapture) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	return b.file.Close()
+}
+
+// updateBodyCapture returns the body capture writer for filename, reusing the
+// current one if its settings are unchanged and closing it otherwise
+func updateBodyCapture(current *bodyCapture, filename string, maxSizeMB int) *bodyCapture {
+	maxSize := maxSizeMB
+	if maxSize <= 0 {
+		maxSize = 10
+	}
+	if current != nil && current.file.Filename == filename && current.file.MaxSize == maxSize {
+		return current
+	}
+	if current != nil {
+		current.Close()
+	}
+	if filename == "" {
+		return nil
+	}
+	return &bodyCapture{file: &lumberjack.Logger{
+		Filename: filename,
+		MaxSize:  maxSize,
+	}}
+}
+
+// captureRequestBody appends the raw body with a metadata header to the capture file.
+// The entry is written in a single call so concurrent requests never interleave.
+func captureRequestBody(capture *bodyCapture, webhook *Webhook, c *gin.Context, requestID string, body []byte) {
+	var entry bytes.Buffer
+	fmt.Fprintf(&entry, "--- %s webhook=%s method=%s path=%s request_id=%s bytes=%d ---\n",
+		time.Now().Format(time.RFC3339Nano), webhook.ID, c.Request.Method, c.Request.URL.RequestURI(), requestID, len(body))
+	entry.Write(body)
+	entry.WriteString("\n")
+
+	if _, err := capture.Write(entry.Bytes()); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"webhook_id": webhook.ID,
+			"error":      err,
+		}).Error("Failed to capture request body")
+	}
+}
+
+// delayRule is one compiled delay_pattern entry
+type delayRule struct {
+	source string          // the rule as written, used as its metrics key
+	every  int64           // fires on every Nth request (every:N => D)
+	delay  time.Duration   // delay for every rules
+	cycle  []time.Duration // delays cycled per request (sequence: D1, D2, ...)
+}
+
+// delayPattern picks a delay from the per-webhook request number
+type delayPattern struct {
+	rules   []delayRule
+	counter atomic.Int64 // requests evaluated since the pattern was last changed
+}
+
+// parsePatternDelay parses a duration like "250ms" or "1s"; a bare number is milliseconds
+func parsePatternDelay(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if ms, err := strconv.Atoi(value); err == nil {
+		value = strconv.Itoa(ms) + "ms"
+	}
+	delay, err := time.ParseDuration(value)
+	if err != nil || delay < 0 {
+		return 0, fmt.Errorf("invalid delay %q", value)
+	}
+	return delay, nil
+}
+
+// newDelayPattern compiles delay_pattern rules. Supported forms:
+//
+//	every:N => DELAY      requests N, 2N, 3N, ... wait DELAY
+//	sequence: D1, D2, ... request k waits D((k-1) mod len)
+//
+// It returns nil when no rules are configured.
+func newDelayPattern(rules []string) (*delayPattern, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	pattern := &delayPattern{}
+	for _, source := range rules {
+		kind, arg, ok := strings.Cut(strings.TrimSpace(source), ":")
+		if !ok {
+			return nil, fmt.Errorf("delay_pattern %q: expected every:N => DELAY or sequence: D1, D2, ...", source)
+		}
+		rule := delayRule{source: source}
+		switch strings.TrimSpace(kind) {
+		case "every":
+			count, delay, ok := strings.Cut(arg, "=>")
+			if !ok {
+				return nil, fmt.Errorf("delay_pattern %q: missing => DELAY", source)
+			}
+			every, err := strconv.ParseInt(strings.TrimSpace(count), 10, 64)
+			if err != nil || every < 1 {
+				return nil, fmt.Errorf("delay_pattern %q: every needs a positive request count", source)
+			}
+			rule.every = every
+			if rule.delay, err = parsePatternDelay(delay); err != nil {
+				return nil, fmt.Errorf("delay_pattern %q: %w", source, err)
+			}
+		case "sequence":
+			for _, item := range strings.Split(arg, ",") {
+				delay, err := parsePatternDelay(item)
+				if err != nil {
+					return nil, fmt.Errorf("delay_pattern %q: %w", source, err)
+				}
+				rule.cycle = append(rule.cycle, delay)
+			}
+		default:
+			return nil, fmt.Errorf("delay_pattern %q: unknown rule %q (use every or sequence)", source, kind)
+		}
+		pattern.rules = append(pattern.rules, rule)
+	}
+	return pattern, nil
+}
+
+// next counts a request and returns the delay of the first matching rule
+func (p *delayPattern) next() (time.Duration, string, bool) {
+	return p.match(p.counter.Add(1))
+}
+
+// peek returns what next would return, without counting a request
+func (p *delayPattern) peek() (time.Duration, string, bool) {
+	return p.match(p.counter.Load() + 1)
+}
+
+// match returns the delay of the first rule matching request number n
+func (p *delayPattern) match(n int64) (time.Duration, string, bool) {
+	for _, rule := range p.rules {
+		if rule.cycle != nil {
+			return rule.cycle[(n-1)%int64(len(rule.cycle))], rule.source, true
+		}
+		if n%rule.every == 0 {
+			return rule.delay, rule.source, true
+		}
+	}
+	return 0, "", false
+}
+
+// peekRequestBody reads the request body and restores it so later readers see it again
+func peekRequestBody(c *gin.Context) ([]byte, error) {
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	return bodyBytes, err
+}
+
+// ipBackoffTracker counts recent hits per client IP to compute escalating delays.
+// Memory is bounded by MaxTrackedIPs; expired entries are evicted first, then the
+// least recently seen IP.
+type ipBackoffTracker struct {
+	mu      sync.Mutex
+	config  BackoffConfig
+	clients map[string]*ipBackoffEntry
+}
+
+type ipBackoffEntry struct {
+	hits    int
+	lastHit time.Time
+}
+
+// newIPBackoffTracker validates the backoff config and applies defaults.
+// It returns nil when backoff is not configured.
+func newIPBackoffTracker(config *BackoffConfig) (*ipBackoffTracker, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	effective := *config
+	if effective.Multiplier == 0 {
+		effective.Multiplier = 2
+	}
+	if effective.MaxDelay == 0 {
+		effective.MaxDelay = 30000
+	}
+	if effective.Cooldown == 0 {
+		effective.Cooldown = 10000
+	}
+	if effective.MaxTrackedIPs == 0 {
+		effective.MaxTrackedIPs = 1000
+	}
+
+	if effective.InitialDelay < 0 || effective.MaxDelay < 0 || effective.Cooldown < 0 || effective.MaxTrackedIPs < 0 {
+		return nil, errors.New("backoff values must not be negative")
+	}
+	if effective.Multiplier < 1 {
+		return nil, fmt.Errorf("backoff multiplier must be at least 1, got %v", effective.Multiplier)
+	}
+
+	return &ipBackoffTracker{
+		config:  effective,
+		clients: make(map[string]*ipBackoffEntry),
+	}, nil
+}
+
+// hit records a request from ip and returns the extra delay it should receive
+func (b *ipBackoffTracker) hit(ip string, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cooldown := time.Duration(b.config.Cooldown) * time.Millisecond
+	entry, exists := b.clients[ip]
+	if !exists {
+		if len(b.clients) >= b.config.MaxTrackedIPs {
+			b.evictLocked(now, cooldown)
+		}
+		entry = &ipBackoffEntry{}
+		b.clients[ip] = entry
+	} else if now.Sub(entry.lastHit) > cooldown {
+		entry.hits = 0
+	}
+
+	entry.hits++
+	entry.lastHit = now
+	return b.delayFor(entry.hits)
+}
+
+// peek returns the extra delay the next request from ip would receive, without recording it
+func (b *ipBackoffTracker) peek(ip string, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hits := 1
+	if entry, exists := b.clients[ip]; exists && now.Sub(entry.lastHit) <= time.Duration(b.config.Cooldown)*time.Millisecond {
+		hits = entry.hits + 1
+	}
+	return b.delayFor(hits)
+}
+
+// delayFor returns the extra delay of a client's hits-th recent request
+func (b *ipBackoffTracker) delayFor(hits int) time.Duration {
+	if hits < 2 {
+		return 0
+	}
+	delayMs := float64(b.config.InitialDelay) * math.Pow(b.config.Multiplier, float64(hits-2))
+	if delayMs > float64(b.config.MaxDelay) {
+		delayMs = float64(b.config.MaxDelay)
+	}
+	return time.Duration(delayMs * float64(time.Millisecond))
+}
+
+// evictLocked drops expired entries, or the least recently seen one if none expired.
+// The caller must hold b.mu.
+func (b *ipBackoffTracker) evictLocked(now time.Time, cooldown time.Duration) {
+	var oldestIP string
+	var oldestHit time.Time
+	for ip, entry := range b.clients {
+		if now.Sub(entry.lastHit) > cooldown {
+			delete(b.clients, ip)
+			continue
+		}
+		if oldestIP == "" || entry.lastHit.Before(oldestHit) {
+			oldestIP, oldestHit = ip, entry.lastHit
+		}
+	}
+	if len(b.clients) >= b.config.MaxTrackedIPs && oldestIP != "" {
+		delete(b.clients, oldestIP)
+	}
+}
+
+// throttledCount returns how many IPs are currently receiving an extra delay
+func (b *ipBackoffTracker) throttledCount(now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cooldown := time.Duration(b.config.Cooldown) * time.Millisecond
+	count := 0
+	for _, entry := range b.clients {
+		if entry.hits >= 2 && now.Sub(entry.lastHit) <= cooldown {
+			count++
+		}
+	}
+	return count
+}
+
+// defaultLatencyBuckets are the Prometheus client default bucket bounds in seconds
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// latencyBucketsOrDefault validates configured bucket bounds: they must be
+// positive and strictly increasing, otherwise the defaults are used
+func latencyBucketsOrDefault(buckets []float64) []float64 {
+	if len(buckets) == 0 {
+		return defaultLatencyBuckets
+	}
+	for i, bound := range buckets {
+		if bound <= 0 || (i > 0 && bound <= buckets[i-1]) {
+			logrus.Warnf("Ignoring prometheus.latency_buckets %v: bounds must be positive and increasing", buckets)
+			return defaultLatencyBuckets
+		}
+	}
+	return buckets
+}
+
+// promHistogram is a cumulative Prometheus-style histogram; it is never reset
+type promHistogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64 // per bucket, not cumulative; the last entry is +Inf
+	sum    float64
+	count  uint64
+}
+
+func newPromHistogram(bounds []float64) *promHistogram {
+	return &promHistogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *promHistogram) observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.counts[sort.SearchFloat64s(h.bounds, value)]++
+	h.sum += value
+	h.count++
+}
+
+// writeTo appends the histogram's series in Prometheus text format
+func (h *promHistogram) writeTo(builder *strings.Builder, name, labels string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(builder, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(builder, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(builder, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(builder, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// latencyHistograms splits a webhook's latency into handler work and intentional delay
+type latencyHistograms struct {
+	processing *promHistogram // total latency minus the configured delay
+	delay      *promHistogram // the configured delay that was slept
+}
+
+// webhookHistograms returns the webhook's histograms, creating them on first use
+func (ws *WebhookServer) webhookHistograms(id string) *latencyHistograms {
+	ws.histogramsMu.Lock()
+	defer ws.histogramsMu.Unlock()
+
+	histograms, exists := ws.histograms[id]
+	if !exists {
+		histograms = &latencyHistograms{
+			processing: newPromHistogram(ws.latencyBuckets),
+			delay:      newPromHistogram(ws.latencyBuckets),
+		}
+		ws.histograms[id] = histograms
+	}
+	return histograms
+}
+
+// prometheusMetrics renders per-webhook TPS and latency histograms in the
+// Prometheus text exposition format
+func (ws *WebhookServer) prometheusMetrics(precision int) string {
+	webhooks := ws.getAllWebhooks()
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+
+	var builder strings.Builder
+	builder.WriteString("# HELP webhook_tps Cumulative transactions per second since the last reset.\n")
+	builder.WriteString("# TYPE webhook_tps gauge\n")
+	for _, webhook := range webhooks {
+		_, tps := webhook.Calculator.Totals()
+		fmt.Fprintf(&builder, "webhook_tps{%s} %s\n", promLabels(webhook), strconv.FormatFloat(roundTPS(tps, precision), 'g', -1, 64))
+	}
+
+	series := []struct {
+		name, help string
+		pick       func(*latencyHistograms) *promHistogram
+	}{
+		{"webhook_processing_seconds", "Request handling time excluding the configured delay.", func(h *latencyHistograms) *promHistogram { return h.processing }},
+		{"webhook_delay_seconds", "Intentional delay applied before responding.", func(h *latencyHistograms) *promHistogram { return h.delay }},
+	}
+	for _, metric := range series {
+		fmt.Fprintf(&builder, "# HELP %s %s\n# TYPE %s histogram\n", metric.name, metric.help, metric.name)
+		for _, webhook := range webhooks {
+			metric.pick(ws.webhookHistograms(webhook.ID)).writeTo(&builder, metric.name, promLabels(webhook))
+		}
+	}
+	return builder.String()
+}
+
+// promLabels formats the identifying labels of a webhook
+func promLabels(webhook *Webhook) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return fmt.Sprintf(`webhook_id="%s",webhook="%s"`, escaper.Replace(webhook.ID), escaper.Replace(webhook.Name))
+}
+
+// escapeInfluxTag escapes a tag key or value for the InfluxDB line protocol
+func escapeInfluxTag(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	replacer := strings.NewReplacer(",", "\\,", "=", "\\=", " ", "\\ ", "\n", "\\n")
+	return replacer.Replace(value)
+}
+
+// webhookMetrics returns the calculator metrics plus webhook-level runtime stats
+func webhookMetrics(webhook *Webhook, percentiles []float64) map[string]interface{} {
+	metrics := webhook.Calculator.GetMetrics(percentiles)
+	if webhook.backoff != nil {
+		metrics["throttled_ips"] = webhook.backoff.throttledCount(time.Now())
+	}
+	if limiter := webhook.limiter; limiter != nil {
+		metrics["queue_depth"] = limiter.queued.Load()
+	}
+	if sequence := webhook.sequence; sequence != nil {
+		metrics["sequence_step"] = sequence.current()
+	}
+	if circuit := webhook.circuit; circuit != nil {
+		metrics["circuit_state"] = circuit.current(time.Now())
+	}
+	return metrics
+}
+
+// matchRequest is a synthetic request for POST /api/webhooks/:id/match
+type matchRequest struct {
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Headers  map[string]string `json:"headers"`
+	Query    map[string]string `json:"query"`
+	Body     string            `json:"body"`
+	ClientIP string            `json:"client_ip"` // defaults to the caller's IP
+}
+
+// matchWebhookRequest works out how the webhook would answer a synthetic
+// request without recording metrics, logging, capturing or sleeping. The
+// checks run in the handler's order and the first one that answers names the
+// rule; stateful features report what the next request would get without
+// advancing their state.
+func matchWebhookRequest(webhook *Webhook, req matchRequest) (gin.H, error) {
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	path := req.Path
+	if path == "" {
+		path = webhook.Path
+	}
+	query := url.Values{}
+	for key, value := range req.Query {
+		query.Set(key, value)
+	}
+
+	request, err := http.NewRequest(method, path+"?"+query.Encode(), strings.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range req.Headers {
+		request.Header.Set(key, value)
+	}
+	c := &gin.Context{Request: request}
+	if webhook.hasPathParams() {
+		params, ok := matchPathParams(webhook.Path, request.URL.Path)
+		if !ok {
+			return nil, fmt.Errorf("path %s does not match the webhook path %s", request.URL.Path, webhook.Path)
+		}
+		c.Params = params
+	}
+
+	result := gin.H{
+		"webhook_id": webhook.ID,
+		"rule":       "default",
+	}
+
+	if length, tooLong := webhook.uriTooLong(request); tooLong {
+		result["rule"] = "max_uri_length"
+		result["status_code"] = http.StatusRequestURITooLong
+		result["body"] = webhook.uriTooLongBody(length)
+		return result, nil
+	}
+
+	if breaker := webhook.circuit; breaker != nil {
+		now := time.Now()
+		if allowed, retryAfter := breaker.peek(now); !allowed {
+			result["rule"] = "circuit_breaker"
+			result["status_code"] = breaker.statusCode
+			result["headers"] = map[string]string{"Retry-After": retryAfterSeconds(retryAfter)}
+			result["body"] = gin.H{"error": "Circuit breaker is open", "circuit_state": breaker.current(now)}
+			return result, nil
+		}
+	}
+
+	if missing, status := webhook.missingHeaders(c); len(missing) > 0 {
+		result["rule"] = "required_headers"
+		result["status_code"] = status
+		result["body"] = gin.H{"error": "missing required headers", "missing_headers": missing}
+		return result, nil
+	}
+
+	if reason := webhook.checkSignature(c); reason != "" {
+		result["rule"] = "signature_verification"
+		result["status_code"] = http.StatusUnauthorized
+		result["body"] = gin.H{"error": "invalid signature", "details": reason}
+		return result, nil
+	}
+
+	if webhook.requestSchema != nil {
+		if validationErr := validateRequestBody(webhook.requestSchema, c); validationErr != nil {
+			result["rule"] = "request_schema"
+			result["status_code"] = http.StatusUnprocessableEntity
+			result["body"] = validationErr
+			return result, nil
+		}
+	}
+
+	delay, source := webhook.expectedDelay(request, []byte(req.Body), req.ClientIP, time.Now())
+	result["delay_ms"] = delay.Milliseconds()
+	result["delay_source"] = source
+	if webhook.Config.DelayDistribution != nil {
+		result["delay_distribution"] = webhook.Config.DelayDistribution
+	}
+
+	if n := webhook.Config.FailFirstN; n > 0 {
+		if attempt, fail := webhook.Calculator.PeekFailFirst(n); fail {
+			result["rule"] = "fail_first_n"
+			result["status_code"] = http.StatusServiceUnavailable
+			result["body"] = gin.H{
+				"error":        "Failing by design",
+				"attempt":      attempt,
+				"fail_first_n": n,
+			}
+			return result, nil
+		}
+	}
+
+	if webhook.Config.ForwardTo != "" {
+		result["rule"] = "forward_to"
+		result["forward_to"] = webhook.Config.ForwardTo
+		return result, nil
+	}
+
+	headers := cloneHeaders(webhook.Config.Headers)
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	statusCode := webhook.Config.StatusCode
+	contentType := webhook.Config.ContentType
+	var step *ResponseStep
+	if sequence := webhook.sequence; sequence != nil {
+		next, index := sequence.peek()
+		step = &next
+		result["rule"] = "sequence"
+		result["sequence_step"] = index
+		for key, value := range step.Headers {
+			headers[key] = value
+		}
+		headers["X-Sequence-Step"] = strconv.Itoa(index)
+		if step.StatusCode != 0 {
+			statusCode = step.StatusCode
+		}
+	}
+
+	var body string
+	queryBody, found := webhook.queryResponse(c)
+	switch {
+	case step != nil && step.ResponseBody != "":
+		body = step.ResponseBody
+	case webhook.Config.QueryResponseParam != "" && found:
+		result["rule"] = "query_response_map"
+		body = queryBody
+	case webhook.Config.QueryResponseParam != "" && webhook.Config.QueryResponseNotFound:
+		result["rule"] = "query_response_map"
+		result["status_code"] = http.StatusNotFound
+		result["body"] = webhook.queryNotFound(c)
+		return result, nil
+	case webhook.abSplit != nil:
+		// A cookie-sticky client without the cookie is new and gets a random key
+		split := webhook.abSplit
+		key := req.ClientIP
+		if split.cookie != "" {
+			if cookie, err := request.Cookie(split.cookie); err == nil && cookie.Value != "" {
+				key = cookie.Value
+			} else {
+				key = uuid.New().String()
+				headers["Set-Cookie"] = split.stickyCookie(key).String()
+			}
+		}
+		variant := split.variantFor(key)
+		result["rule"] = "ab_split"
+		result["ab_variant"] = variant.Name
+		headers[split.header] = variant.Name
+		body = variant.ResponseBody
+		if variant.StatusCode != 0 && (step == nil || step.StatusCode == 0) {
+			statusCode = variant.StatusCode
+		}
+		if variant.ContentType != "" {
+			contentType = variant.ContentType
+		}
+	case webhook.bodyRotation != nil:
+		index := webhook.bodyRotation.peek(len(webhook.Config.ResponseBodyList))
+		entry := webhook.Config.ResponseBodyList[index]
+		result["rule"] = "response_body_list"
+		result["response_body_index"] = index
+		body = entry.Body
+		if entry.StatusCode != 0 && (step == nil || step.StatusCode == 0) {
+			statusCode = entry.StatusCode
+		}
+		if entry.ContentType != "" {
+			contentType = entry.ContentType
+		}
+	default:
+		if body, err = webhook.renderResponseBody(c); err != nil {
+			result["status_code"] = http.StatusInternalServerError
+			result["error"] = err.Error()
+			return result, nil
+		}
+	}
+	headers["Content-Type"] = contentType
+	if webhook.Config.CacheControl != "" {
+		headers["Cache-Control"] = webhook.Config.CacheControl
+	}
+	if webhook.Config.ETag {
+		headers["ETag"] = bodyETag(body)
+	}
+
+	result["status_code"] = statusCode
+	result["headers"] = headers
+	result["body"] = body
+	return result, nil
+}
+
+// expectedDelay works out the delay handleWebhookRequest would apply to a
+// request and where its base came from, without advancing any tracker. A
+// delay_distribution is sampled per request, so its base stands in for a sample.
+func (w *Webhook) expectedDelay(request *http.Request, body []byte, clientIP string, now time.Time) (time.Duration, string) {
+	baseTimeout := w.Config.Timeout
+	source := "config"
+	if timeout, ok := w.Config.MethodTimeouts[request.Method]; ok {
+		baseTimeout = timeout
+		source = "method"
+	}
+	if pattern := w.delayPattern; pattern != nil {
+		if patternDelay, _, ok := pattern.peek(); ok {
+			baseTimeout = int(patternDelay / time.Millisecond)
+			source = "pattern"
+		}
+	}
+	if sequence := w.sequence; sequence != nil {
+		if step, _ := sequence.peek(); step.DelayMs != nil {
+			baseTimeout = *step.DelayMs
+			source = "sequence"
+		}
+	}
+	if coldStart := w.coldStart; coldStart != nil && coldStart.cold(now) {
+		baseTimeout = int(coldStart.delay / time.Millisecond)
+		source = "cold_start"
+	}
+	if w.Config.AllowClientDelay {
+		if clientDelay, ok := parseClientDelay(request.Header.Get("X-Delay-Ms"), w.Config.MaxClientDelayMs); ok {
+			baseTimeout = clientDelay
+			source = "client"
+		}
+	}
+
+	delay := time.Duration(baseTimeout) * time.Millisecond
+	if w.backoff != nil {
+		delay += w.backoff.peek(clientIP, now)
+	}
+	delay += bodyDelay(w.Config, int64(len(body)))
+	if concurrent := w.Calculator.inFlight.Load(); w.Config.ConcurrencyLatencyFactor > 0 && concurrent > 0 {
+		delay += time.Duration(w.Config.ConcurrencyLatencyFactor * float64(concurrent) * float64(time.Millisecond))
+	}
+	if floor := time.Duration(w.Config.MinLatencyMs) * time.Millisecond; delay < floor {
+		delay = floor
+	}
+	return delay, source
+}
+
+// writeMetricsText renders a metrics map as an aligned plain-text table with
+// sorted keys. Nested per-method maps are flattened to "key.method" rows.
+func writeMetricsText(w io.Writer, metrics map[string]interface{}) {
+	keys := make([]string, 0, len(metrics))
+	for key := range metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, key := range keys {
+		switch value := metrics[key].(type) {
+		case map[string]int64:
+			for _, sub := range sortedKeys(value) {
+				fmt.Fprintf(table, "%s.%s\t%d\n", key, sub, value[sub])
+			}
+		case map[string]float64:
+			for _, sub := range sortedKeys(value) {
+				fmt.Fprintf(table, "%s.%s\t%s\n", key, sub, formatMetricValue(value[sub]))
+			}
+		default:
+			fmt.Fprintf(table, "%s\t%s\n", key, formatMetricValue(value))
+		}
+	}
+	table.Flush()
+}
+
+// formatMetricValue formats one metric for text output; missing values print as "-"
+func formatMetricValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "-"
+	case float64:
+		return strconv.FormatFloat(v, 'f', 3, 64)
+	case WebhookConfig:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// sortedKeys returns the keys of a string-keyed map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// nullable is a PATCH field that tells an explicit null (clear the setting)
+// apart from an omitted field (keep it)
+type nullable[T any] struct {
+	Set   bool // present in the request
+	Value *T   // nil for an explicit null
+}
+
+func (n *nullable[T]) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+	n.Value = new(T)
+	return json.Unmarshal(data, n.Value)
+}
+
+// mergeWebhookConfig applies the non-zero fields of src onto dst (PUT merge
+// semantics). Headers are merged key by key; enable_logging is always taken from src.
+func mergeWebhookConfig(dst *WebhookConfig, src WebhookConfig) {
+	if src.StatusCode != 0 {
+		dst.StatusCode = src.StatusCode
+	}
+	if src.ContentType != "" {
+		dst.ContentType = src.ContentType
+	}
+	if src.ResponseBody != "" {
+		dst.ResponseBody = src.ResponseBody
+	}
+	if src.Timeout >= 0 {
+		dst.Timeout = src.Timeout
+	}
+	if src.WriteChunkSize != 0 {
+		dst.WriteChunkSize = src.WriteChunkSize
+	}
+	if src.WriteDelayPerChunk != 0 {
+		dst.WriteDelayPerChunk = src.WriteDelayPerChunk
+	}
+	if src.RequestSchema != "" {
+		dst.RequestSchema = src.RequestSchema
+	}
+	if src.RequestSchemaFile != "" {
+		dst.RequestSchemaFile = src.RequestSchemaFile
+	}
+	if src.LogSampleRate != 0 {
+		dst.LogSampleRate = src.LogSampleRate
+	}
+	if src.Backoff != nil {
+		dst.Backoff = src.Backoff
+	}
+	if src.DelayDistribution != nil {
+		dst.DelayDistribution = src.DelayDistribution
+	}
+	if src.LingerMs != 0 {
+		dst.LingerMs = src.LingerMs
+	}
+	if src.CaptureBodiesTo != "" {
+		dst.CaptureBodiesTo = src.CaptureBodiesTo
+	}
+	if src.CaptureMaxSizeMB != 0 {
+		dst.CaptureMaxSizeMB = src.CaptureMaxSizeMB
+	}
+	if src.ResponseBodyFile != "" {
+		dst.ResponseBodyFile = src.ResponseBodyFile
+	}
+	if src.ResponseTemplate {
+		dst.ResponseTemplate = true
+	}
+	if src.PrettyJSON {
+		dst.PrettyJSON = true
+	}
+	if src.HandlerTimeout != 0 {
+		dst.HandlerTimeout = src.HandlerTimeout
+	}
+	if src.ETag {
+		dst.ETag = true
+	}
+	if src.CacheControl != "" {
+		dst.CacheControl = src.CacheControl
+	}
+	if src.MaxConcurrency != 0 {
+		dst.MaxConcurrency = src.MaxConcurrency
+	}
+	if src.MaxQueue != 0 {
+		dst.MaxQueue = src.MaxQueue
+	}
+	if src.AllowClientDelay {
+		dst.AllowClientDelay = true
+	}
+	if src.MaxClientDelayMs != 0 {
+		dst.MaxClientDelayMs = src.MaxClientDelayMs
+	}
+	if src.MethodTimeouts != nil {
+		dst.MethodTimeouts = src.MethodTimeouts
+	}
+	if src.ForwardTo != "" {
+		dst.ForwardTo = src.ForwardTo
+	}
+	if src.ForwardTimeout != 0 {
+		dst.ForwardTimeout = src.ForwardTimeout
+	}
+	if src.LogHeaderAllowlist != nil {
+		dst.LogHeaderAllowlist = src.LogHeaderAllowlist
+	}
+	if src.LogHeaderDenylist != nil {
+		dst.LogHeaderDenylist = src.LogHeaderDenylist
+	}
+	if src.LogHeaderRedact != nil {
+		dst.LogHeaderRedact = src.LogHeaderRedact
+	}
+	if src.LogHeaderMaxBytes != 0 {
+		dst.LogHeaderMaxBytes = src.LogHeaderMaxBytes
+	}
+	if src.SignatureVerification != nil {
+		dst.SignatureVerification = src.SignatureVerification
+	}
+	if src.ConcurrencyLatencyFactor != 0 {
+		dst.ConcurrencyLatencyFactor = src.ConcurrencyLatencyFactor
+	}
+	if src.FailOnRepeat != nil {
+		dst.FailOnRepeat = src.FailOnRepeat
+	}
+	if src.DisableClientTracking {
+		dst.DisableClientTracking = true
+	}
+	if src.DelayPattern != nil {
+		dst.DelayPattern = src.DelayPattern
+	}
+	if src.DelayPerKB != 0 {
+		dst.DelayPerKB = src.DelayPerKB
+	}
+	if src.MaxBodyDelayMs != 0 {
+		dst.MaxBodyDelayMs = src.MaxBodyDelayMs
+	}
+	if src.ColdStart != nil {
+		dst.ColdStart = src.ColdStart
+	}
+	if src.Sequence != nil {
+		dst.Sequence = src.Sequence
+	}
+	if src.RandomSeed != 0 {
+		dst.RandomSeed = src.RandomSeed
+	}
+	if src.RequiredHeaders != nil {
+		dst.RequiredHeaders = src.RequiredHeaders
+	}
+	if src.RequiredHeadersStatus != 0 {
+		dst.RequiredHeadersStatus = src.RequiredHeadersStatus
+	}
+	if src.ServerTiming {
+		dst.ServerTiming = true
+	}
+	if src.FanOutTo != nil {
+		dst.FanOutTo = src.FanOutTo
+	}
+	if src.MinLatencyMs != 0 {
+		dst.MinLatencyMs = src.MinLatencyMs
+	}
+	if src.ResponseSigning != nil {
+		dst.ResponseSigning = src.ResponseSigning
+	}
+	if src.LogBodyJSONPaths != nil {
+		dst.LogBodyJSONPaths = src.LogBodyJSONPaths
+	}
+	if src.DeadlineBudgetMs != 0 {
+		dst.DeadlineBudgetMs = src.DeadlineBudgetMs
+	}
+	if src.QueryResponseParam != "" {
+		dst.QueryResponseParam = src.QueryResponseParam
+	}
+	if src.QueryResponseMap != nil {
+		dst.QueryResponseMap = src.QueryResponseMap
+	}
+	if src.QueryResponseNotFound {
+		dst.QueryResponseNotFound = true
+	}
+	if src.ResponseBodyList != nil {
+		dst.ResponseBodyList = src.ResponseBodyList
+	}
+	if src.ForceChunked {
+		dst.ForceChunked = true
+	}
+	if src.StartupDelayMs != 0 {
+		dst.StartupDelayMs = src.StartupDelayMs
+	}
+	if src.MetricsLabelJSONPath != "" {
+		dst.MetricsLabelJSONPath = src.MetricsLabelJSONPath
+	}
+	if src.MetricsLabelMaxValues != 0 {
+		dst.MetricsLabelMaxValues = src.MetricsLabelMaxValues
+	}
+	if src.FailFirstN != 0 {
+		dst.FailFirstN = src.FailFirstN
+	}
+	if src.TraceRequests {
+		dst.TraceRequests = true
+	}
+	if src.CircuitBreaker != nil {
+		dst.CircuitBreaker = src.CircuitBreaker
+	}
+	if src.MaxURILength != 0 {
+		dst.MaxURILength = src.MaxURILength
+	}
+	if src.ABSplit != nil {
+		dst.ABSplit = src.ABSplit
+	}
+	if src.Headers != nil {
+		// Merge into a copy: snapshots handed out by getWebhook share the old map
+		dst.Headers = cloneHeaders(dst.Headers)
+		if dst.Headers == nil {
+			dst.Headers = make(map[string]string)
+		}
+		for key, value := range src.Headers {
+			dst.Headers[key] = value
+		}
+	}
+	dst.EnableLogging = src.EnableLogging
+}
+
+// cloneHeaders returns a copy of a header map
+func cloneHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	cloned := make(map[string]string, len(headers))
+	for key, value := range headers {
+		cloned[key] = value
+	}
+	return cloned
+}
+
+// Used when server.files_dir and server.capture_dir are not set
+const (
+	defaultFilesDir   = "."
+	defaultCaptureDir = "captures"
+)
+
+// webhookDirs are the directories webhook file paths are resolved against:
+// files for the files webhooks read, captures for body capture files
+type webhookDirs struct {
+	files    string
+	captures string
+}
+
+// resolveUnder resolves a file path from a webhook config against dir.
+// Since configs also arrive through the API, absolute paths and paths
+// leaving the directory are rejected.
+func resolveUnder(dir, field, name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("%s must be a relative path inside %q", field, dir)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// compileRequestSchema compiles the configured request JSON Schema, if any;
+// request_schema_file is resolved under filesDir
+func compileRequestSchema(config WebhookConfig, filesDir string) (*jsonschema.Schema, error) {
+	switch {
+	case config.RequestSchema != "":
+		compiler := jsonschema.NewCompiler()
+		if err := compiler.AddResource("mem://webhook/request_schema.json", strings.NewReader(config.RequestSchema)); err != nil {
+			return nil, fmt.Errorf("invalid request_schema: %w", err)
+		}
+		schema, err := compiler.Compile("mem://webhook/request_schema.json")
+		if err != nil {
+			return nil, fmt.Errorf("invalid request_schema: %w", err)
+		}
+		return schema, nil
+	case config.RequestSchemaFile != "":
+		path, err := resolveUnder(filesDir, "request_schema_file", config.RequestSchemaFile)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := jsonschema.Compile(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request_schema_file: %w", err)
+		}
+		return schema, nil
+	}
+	return nil, nil
+}
+
+func (ws *WebhookServer) createWebhook(id, name, path string, tags []string, config WebhookConfig, ttl time.Duration) (*Webhook, error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.maxWebhooks > 0 && len(ws.webhooks) >= ws.maxWebhooks {
+		return nil, withCode(codeWebhookLimit, fmt.Errorf("webhook limit of %d reached", ws.maxWebhooks))
+	}
+
+	webhook, err := ws.buildWebhookLocked(id, name, path, tags, config, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if err := ws.checkFanOutLocked(webhook); err != nil {
+		return nil, err
+	}
+
+	ws.webhooks[webhook.ID] = webhook
+
+	// Register the custom path route
+	ws.registerWebhookRoute(webhook)
+
+	return webhook, nil
+}
+
+// maxWebhookIDLength bounds client-chosen webhook IDs
+const maxWebhookIDLength = 64
+
+// validateWebhookID accepts IDs that are safe in URLs, file names and metric
+// labels: letters, digits, '-' and '_'
+func validateWebhookID(id string) error {
+	if len(id) > maxWebhookIDLength {
+		return fmt.Errorf("id must be at most %d characters", maxWebhookIDLength)
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("id %q may only contain letters, digits, '-' and '_'", id)
+		}
+	}
+	return nil
+}
+
+// reservedPathPrefixes belong to the management API, web UI and health
+// endpoints. Webhook paths may not equal or live under them; the root path "/"
+// is reserved as an exact match only.
+var reservedPathPrefixes = []string{"/api", "/static", "/metrics", "/healthz", "/readyz"}
+
+// validateWebhookPath rejects webhook paths that would collide with reserved routes
+func validateWebhookPath(path string) error {
+	if path == "/" {
+		return withCode(codePathConflict, fmt.Errorf("path / is reserved"))
+	}
+	for _, prefix := range reservedPathPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return withCode(codePathConflict, fmt.Errorf("path %s is reserved: webhook paths may not be under %s", path, prefix))
+		}
+	}
+	params, err := pathParams(path)
+	if err != nil {
+		return withCode(codeInvalidRequest, err)
+	}
+	if len(params) > 0 && strings.HasPrefix(path, "/w/") {
+		return withCode(codePathConflict, fmt.Errorf("path %s: parameters are not allowed under /w/, which serves webhooks by ID", path))
+	}
+	// A parameter as the first segment would match every unknown URL of its
+	// depth, taking them from the catch-all webhook
+	if strings.HasPrefix(path, "/:") {
+		return withCode(codePathConflict, fmt.Errorf("path %s: the first segment can't be a parameter", path))
+	}
+	return nil
+}
+
+// pathParams returns the names of the :name segments of a webhook path.
+// Parameters must be whole segments named with letters, digits and '_',
+// distinct within the path; '*' wildcards are not supported. In routing, a
+// static segment wins over a parameter at the same position, so /w/:id and
+// other static routes are never shadowed, and the catch-all only sees
+// requests that no path, parameterized or not, matched.
+func pathParams(path string) ([]string, error) {
+	var params []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.Contains(segment, "*") {
+			return nil, fmt.Errorf("path %s: wildcard segments are not supported", path)
+		}
+		if !strings.HasPrefix(segment, ":") {
+			if strings.Contains(segment, ":") {
+				return nil, fmt.Errorf("path %s: a parameter must be a whole segment like /:name", path)
+			}
+			continue
+		}
+		name := segment[1:]
+		if name == "" || name[0] >= '0' && name[0] <= '9' {
+			return nil, fmt.Errorf("path %s: parameter %q must start with a letter or '_'", path, segment)
+		}
+		for _, r := range name {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+				return nil, fmt.Errorf("path %s: parameter %q may only contain letters, digits and '_'", path, segment)
+			}
+		}
+		for _, existing := range params {
+			if existing == name {
+				return nil, fmt.Errorf("path %s: parameter %q is used twice", path, name)
+			}
+		}
+		params = append(params, name)
+	}
+	return params, nil
+}
+
+// routesConflict reports whether gin would refuse to register both paths:
+// after a shared prefix, both have a parameter at the same position but with
+// different names. Static segments next to parameters are fine.
+func routesConflict(a, b string) bool {
+	segmentsA, segmentsB := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(segmentsA) && i < len(segmentsB); i++ {
+		paramA, paramB := strings.HasPrefix(segmentsA[i], ":"), strings.HasPrefix(segmentsB[i], ":")
+		if paramA && paramB && segmentsA[i] != segmentsB[i] {
+			return true
+		}
+		if segmentsA[i] != segmentsB[i] {
+			return false
+		}
+	}
+	return false
+}
+
+// checkRouteLocked rejects a path whose parameters clash with a registered
+// route. Gin keeps routes of deleted webhooks, so those count too.
+// Callers must hold ws.mu.
+func (ws *WebhookServer) checkRouteLocked(path string) error {
+	for registered := range ws.routes {
+		if routesConflict(path, registered) {
+			return withCode(codePathConflict, fmt.Errorf("path %s conflicts with route %s: parameters at the same position must have the same name", path, registered))
+		}
+	}
+	return nil
+}
+
+// hasPathParams reports whether the webhook's path has :name parameters
+func (w *Webhook) hasPathParams() bool {
+	return strings.Contains(w.Path, "/:")
+}
+
+// matchPathParams matches a request path against a webhook path segment by
+// segment, returning the values of its :name parameters
+func matchPathParams(pattern, path string) (gin.Params, bool) {
+	patternSegments, pathSegments := strings.Split(pattern, "/"), strings.Split(path, "/")
+	if len(patternSegments) != len(pathSegments) {
+		return nil, false
+	}
+	var params gin.Params
+	for i, segment := range patternSegments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			if pathSegments[i] == "" {
+				return nil, false
+			}
+			params = append(params, gin.Param{Key: segment[1:], Value: pathSegments[i]})
+		case segment != pathSegments[i]:
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// createWebhookRequest is the body of POST /api/webhooks (and each item of the bulk variant)
+type createWebhookRequest struct {
+	ID     string        `json:"id"` // optional stable ID; random when empty
+	Name   string        `json:"name" binding:"required"`
+	Path   string        `json:"path"`
+	Tags   []string      `json:"tags"`
+	TTL    string        `json:"ttl"` // e.g. "30m"; the webhook is deleted once it expires
+	Config WebhookConfig `json:"config"`
+}
+
+// parseTTL returns the requested time-to-live, or 0 when none was given
+func (req createWebhookRequest) parseTTL() (time.Duration, error) {
+	if req.TTL == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(req.TTL)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("ttl must be a positive duration like \"30m\", got %q", req.TTL)
+	}
+	return ttl, nil
+}
+
+// applyCreateDefaults fills in the response defaults for a new webhook
+func applyCreateDefaults(config *WebhookConfig) {
+	if config.StatusCode == 0 {
+		config.StatusCode = 200
+	}
+	if config.ContentType == "" {
+		config.ContentType = "application/json"
+	}
+	if config.ResponseBody == "" {
+		config.ResponseBody = `{"message": "Request received"}`
+	}
+	if config.Headers == nil {
+		config.Headers = make(map[string]string)
+	}
+	// EnableLogging defaults to true if not specified
+}
+
+// createWebhooks creates a batch of webhooks. Every item is validated first
+// (config, path collisions within the batch and with existing webhooks,
+// fan_out_to targets among existing webhooks and the batch, and the webhook
+// limit across the whole batch). With atomic set, any failure creates nothing.
+// Failures are keyed by the item's index in the batch.
+func (ws *WebhookServer) createWebhooks(requests []createWebhookRequest, atomic bool) ([]*Webhook, map[int]string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	valid := make([]*Webhook, 0, len(requests))
+	failed := make(map[int]string)
+	batchPaths := make(map[string]int)
+	batchIDs := make(map[string]int)
+
+	for i, req := range requests {
+		if req.Name == "" {
+			failed[i] = "name is required"
+			continue
+		}
+		applyCreateDefaults(&req.Config)
+
+		ttl, err := req.parseTTL()
+		if err != nil {
+			failed[i] = err.Error()
+			continue
+		}
+
+		webhook, err := ws.buildWebhookLocked(req.ID, req.Name, req.Path, req.Tags, req.Config, ttl)
+		if err != nil {
+			failed[i] = err.Error()
+			continue
+		}
+		if other, dup := batchIDs[webhook.ID]; dup {
+			failed[i] = fmt.Sprintf("id %s is already used by item %d of this batch", webhook.ID, other)
+			continue
+		}
+		if other, dup := batchPaths[webhook.Path]; dup {
+			failed[i] = fmt.Sprintf("path %s is already used by item %d of this batch", webhook.Path, other)
+			continue
+		}
+		if ws.maxWebhooks > 0 && len(ws.webhooks)+len(valid) >= ws.maxWebhooks {
+			failed[i] = fmt.Sprintf("webhook limit of %d reached", ws.maxWebhooks)
+			continue
+		}
+		batchPaths[webhook.Path] = i
+		batchIDs[webhook.ID] = i
+		valid = append(valid, webhook)
+	}
+
+	// fan_out_to may name other items of the batch, so targets are checked once
+	// all IDs are known. Dropping an item can strand items targeting it, hence
+	// the repeat until nothing changes.
+	exists := func(id string) bool {
+		_, existing := ws.webhooks[id]
+		_, batched := batchIDs[id]
+		return existing || batched
+	}
+	for changed := true; changed; {
+		changed = false
+		kept := make([]*Webhook, 0, len(valid))
+		for _, webhook := range valid {
+			if err := checkFanOut(webhook.ID, webhook.Config.FanOutTo, exists); err != nil {
+				failed[batchIDs[webhook.ID]] = err.Error()
+				delete(batchIDs, webhook.ID)
+				changed = true
+				continue
+			}
+			kept = append(kept, webhook)
+		}
+		valid = kept
+	}
+
+	if atomic && len(failed) > 0 {
+		return nil, failed
+	}
+
+	for _, webhook := range valid {
+		ws.webhooks[webhook.ID] = webhook
+		ws.registerWebhookRoute(webhook)
+	}
+	return valid, failed
+}
+
+// buildWebhookLocked assigns an ID and path and compiles the config without
+// registering anything. fan_out_to targets are left to the caller, since a
+// batch may target its own items. Callers must hold ws.mu.
+func (ws *WebhookServer) buildWebhookLocked(id, name, path string, tags []string, config WebhookConfig, ttl time.Duration) (*Webhook, error) {
+	if id == "" {
+		// Generate unique ID
+		id = strings.ReplaceAll(uuid.New().String(), "-", "")[:8]
+	} else {
+		if err := validateWebhookID(id); err != nil {
+			return nil, withCode(codeInvalidRequest, err)
+		}
+		// The catch-all webhook is only defined in config.yaml (or created by catch_all)
+		if id == catchAllWebhookID {
+			return nil, withCode(codeInvalidRequest, fmt.Errorf("id %s is reserved for the catch-all webhook", id))
+		}
+		if _, exists := ws.webhooks[id]; exists {
+			return nil, withCode(codeIDConflict, fmt.Errorf("webhook id %s already exists", id))
+		}
+	}
+
+	// Use custom path if provided, otherwise use /w/{id}
+	finalPath := path
+	if finalPath == "" {
+		finalPath = "/w/" + id
+	} else {
+		// Ensure path starts with /
+		if !strings.HasPrefix(finalPath, "/") {
+			finalPath = "/" + finalPath
+		}
+	}
+
+	if err := validateWebhookPath(finalPath); err != nil {
+		return nil, err
+	}
+	if err := ws.checkPathFreeLocked(id, finalPath); err != nil {
+		return nil, err
+	}
+
+	webhook := &Webhook{
+		ID:         id,
+		Name:       name,
+		Path:       finalPath,
+		Tags:       tags,
+		Config:     config,
+		Calculator: NewTPSCalculator(),
+		CreatedAt:  time.Now(),
+	}
+
+	if ttl > 0 {
+		expiresAt := webhook.CreatedAt.Add(ttl)
+		webhook.ExpiresAt = &expiresAt
+	}
+
+	if err := webhook.compileConfig(ws.dirs); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// checkFanOut verifies that every fan_out_to target of webhook id exists
+// according to exists, and that the webhook doesn't fan out to itself
+func checkFanOut(id string, targets []string, exists func(string) bool) error {
+	for _, target := range targets {
+		if target == id {
+			return fmt.Errorf("fan_out_to must not include the webhook itself")
+		}
+		if !exists(target) {
+			return fmt.Errorf("fan_out_to references unknown webhook %s", target)
+		}
+	}
+	return nil
+}
+
+// checkFanOutLocked verifies the webhook's fan_out_to targets against the
+// registered webhooks. Callers must hold ws.mu.
+func (ws *WebhookServer) checkFanOutLocked(webhook *Webhook) error {
+	return checkFanOut(webhook.ID, webhook.Config.FanOutTo, func(id string) bool {
+		_, exists := ws.webhooks[id]
+		return exists
+	})
+}
+
+// registerWebhookRoute points the webhook's path at it. Gin can't unregister
+// routes, so each path is registered once and dispatched through ws.routes;
+// a deleted webhook's path answers 404 until another webhook claims it.
+// Callers must hold ws.mu unless the server isn't serving yet.
+func (ws *WebhookServer) registerWebhookRoute(webhook *Webhook) {
+	path := webhook.Path
+	if _, registered := ws.routes[path]; !registered {
+		ws.router.Any(path, func(c *gin.Context) {
+			ws.mu.RLock()
+			webhookID := ws.routes[path]
+			ws.mu.RUnlock()
+			if webhookID == "" {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+				return
+			}
+			ws.handleWebhookRequest(webhookID, c)
+		})
+	}
+	ws.routes[path] = webhook.ID
+}
+
+// checkPathFreeLocked rejects a path for webhook id when another webhook uses
+// it or its parameters clash with a registered route. Callers must hold ws.mu.
+func (ws *WebhookServer) checkPathFreeLocked(id, path string) error {
+	if err := ws.checkRouteLocked(path); err != nil {
+		return err
+	}
+	// Registering the same route twice would make gin panic
+	for _, existing := range ws.webhooks {
+		if existing.ID != id && existing.Path == path {
+			return withCode(codePathConflict, fmt.Errorf("path %s is already used by webhook %s", path, existing.ID))
+		}
+	}
+	return nil
+}
+
+// rerouteWebhookLocked moves a webhook's route from oldPath to its current
+// path. Gin can't drop routes, so the old one stays registered and answers
+// 404 until a webhook takes the path again. Callers must hold ws.mu.
+func (ws *WebhookServer) rerouteWebhookLocked(webhook *Webhook, oldPath string) {
+	if webhook.Path == oldPath {
+		return
+	}
+	if ws.routes[oldPath] == webhook.ID {
+		ws.routes[oldPath] = ""
+	}
+	ws.registerWebhookRoute(webhook)
+}
+
+// removeWebhookLocked unregisters a webhook and releases its resources.
+// Callers must hold ws.mu.
+func (ws *WebhookServer) removeWebhookLocked(webhook *Webhook) {
+	if webhook.capture != nil {
+		webhook.capture.Close()
+	}
+	// Keep the key: the gin route stays registered and now answers 404
+	if ws.routes[webhook.Path] == webhook.ID {
+		ws.routes[webhook.Path] = ""
+	}
+	ws.histogramsMu.Lock()
+	delete(ws.histograms, webhook.ID)
+	ws.histogramsMu.Unlock()
+	ws.recentMu.Lock()
+	if buffer, exists := ws.recent[webhook.ID]; exists {
+		buffer.release()
+		delete(ws.recent, webhook.ID)
+	}
+	ws.recentMu.Unlock()
+	ws.forgetClients(webhook.ID)
+	ws.errorsMu.Lock()
+	if buffer, exists := ws.errors[webhook.ID]; exists {
+		buffer.release()
+		delete(ws.errors, webhook.ID)
+	}
+	ws.errorsMu.Unlock()
+	delete(ws.webhooks, webhook.ID)
+}
+
+// runExpiryJanitor periodically deletes webhooks whose TTL has passed, until
+// ctx is done. Built-in webhooks are never expired.
+func (ws *WebhookServer) runExpiryJanitor(ctx context.Context, interval time.Duration) {
+	defer ws.trackTask("expiry_janitor")()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+
+		ws.mu.Lock()
+		for _, webhook := range ws.webhooks {
+			if !webhook.Builtin && webhook.ExpiresAt != nil && now.After(*webhook.ExpiresAt) {
+				ws.removeWebhookLocked(webhook)
+				logrus.WithFields(logrus.Fields{
+					"webhook_id": webhook.ID,
+					"webhook":    webhook.Name,
+					"expires_at": webhook.ExpiresAt.Format(time.RFC3339),
+				}).Info("Webhook expired and was deleted")
+			}
+		}
+		ws.mu.Unlock()
+	}
+}
+
+// canonicalLocation returns where a /w/:id request should go when the webhook
+// has a custom path. The catch-all webhook has no path of its own and is
+// always served on /w/catchall.
+func (ws *WebhookServer) canonicalLocation(id string, requestURL *url.URL) (string, bool) {
+	webhook, exists := ws.getWebhook(id)
+	if !exists || id == catchAllWebhookID || webhook.Path == "/w/"+id {
+		return "", false
+	}
+	location := webhook.Path
+	if requestURL.RawQuery != "" {
+		location += "?" + requestURL.RawQuery
+	}
+	return location, true
+}
+
+// shutdownTimeout bounds how long in-flight requests may finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
+// runSummaryLogger logs a one-line snapshot of every webhook that has seen
+// traffic each interval, independent of per-webhook enable_logging, until ctx is done
+func (ws *WebhookServer) runSummaryLogger(ctx context.Context, interval time.Duration) {
+	defer ws.trackTask("summary_logger")()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		webhooks := ws.getAllWebhooks()
+		sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+		for _, webhook := range webhooks {
+			metrics := webhook.Calculator.GetMetrics(ws.percentiles)
+			if metrics["start_time"] == nil {
+				continue
+			}
+			roundMetricsTPS(metrics, ws.tpsPrecision)
+			logrus.WithFields(logrus.Fields{
+				"webhook_id":     webhook.ID,
+				"tps":            metrics["tps"],
+				"active_tps":     metrics["active_tps"],
+				"total_requests": metrics["total_requests"],
+				"status_4xx":     metrics["status_4xx"],
+				"status_5xx":     metrics["status_5xx"],
+				"timeout_errors": metrics["timeout_errors"],
+			}).Info("Webhook summary")
+		}
+	}
+}
+
+func (ws *WebhookServer) handleWebhookRequest(webhookID string, c *gin.Context) {
+	// Update last request time; the rest of the handler works on the snapshot
+	// so a concurrent update cannot change the config halfway through
+	now := time.Now()
+	webhook, exists := ws.touchWebhook(webhookID, now)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	// Per-client counts; turning tracking off also drops what was collected
+	if !webhook.Config.DisableClientTracking {
+		ws.clientTracker(webhook.ID).hit(c.ClientIP(), now)
+		webhook.Calculator.RecordClient(c.ClientIP())
+		ws.calculator.RecordClient(c.ClientIP())
+	} else {
+		ws.forgetClients(webhook.ID)
+	}
+
+	// Lifecycle timestamps, only when tracing is on
+	var trace *requestTrace
+	if webhook.Config.TraceRequests {
+		trace = newRequestTrace(now)
+	}
+
+	// Circuit breaker outcome of an admitted request, fed back once it completes
+	var circuit *circuitBreaker
+	var circuitTrial bool
+
+	// Count whatever status was actually sent, whichever path produced it,
+	// and keep a summary for GET /api/webhooks/:id/requests.jsonl
+	var requestID string
+	defer func() {
+		if circuit != nil {
+			if !c.Writer.Written() {
+				circuit.release(circuitTrial)
+			} else if state := circuit.record(c.Writer.Status() >= 500, circuitTrial, time.Now()); state != "" {
+				if state == circuitOpen {
+					webhook.Calculator.RecordCircuitOpen()
+				}
+				logrus.WithFields(logrus.Fields{
+					"webhook_id": webhook.ID,
+					"state":      state,
+				}).Warn("Circuit breaker state changed")
+			}
+		}
+		if c.Writer.Written() {
+			status := c.Writer.Status()
+			var events []TraceEvent
+			if trace != nil {
+				trace.mark("completed")
+				events = trace.events
+				logrus.WithFields(logrus.Fields{
+					"webhook_id":  webhook.ID,
+					"request_id":  requestID,
+					"status":      status,
+					"received_at": now.Format(time.RFC3339Nano),
+					"trace_ms":    trace.String(),
+				}).Info("Request trace")
+			}
+			webhook.Calculator.RecordStatus(status)
+			ws.calculator.RecordStatus(status)
+			ws.requestBuffer(webhook.ID).add(RequestSummary{
+				Time:          now,
+				RequestID:     requestID,
+				Method:        c.Request.Method,
+				Path:          c.Request.URL.Path,
+				Query:         c.Request.URL.RawQuery,
+				IP:            c.ClientIP(),
+				Status:        status,
+				ContentLength: c.Request.ContentLength,
+				LatencyMs:     float64(time.Since(now)) / float64(time.Millisecond),
+				Trace:         events,
+			})
+		}
+	}()
+
+	// Bound the handler with a deadline; clientCtx still reports client disconnects
+	clientCtx := c.Request.Context()
+	if webhook.Config.HandlerTimeout > 0 {
+		ctx, cancel := context.WithTimeout(clientCtx, time.Duration(webhook.Config.HandlerTimeout)*time.Millisecond)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+	}
+
+	// Only a sample of requests is logged in full; errors are always logged
+	logDetails := webhook.Config.EnableLogging && sampleLog(webhook.rand, webhook.Config.LogSampleRate)
+
+	// Correlation ID: honor the client's X-Request-ID, otherwise generate one
+	// (only when logging, since there is nothing to correlate with otherwise)
+	requestID = c.GetHeader("X-Request-ID")
+	if requestID == "" && webhook.Config.EnableLogging {
+		requestID = uuid.New().String()
+	}
+	if requestID != "" {
+		c.Header("X-Request-ID", requestID)
+	}
+
+	// Not ready during this webhook's startup delay, or the server's with startup_block_webhooks
+	wait := webhook.startup.remaining(now)
+	if ws.startupBlocksWebhooks {
+		if serverWait := ws.startup.remaining(now); serverWait > wait {
+			wait = serverWait
+		}
+	}
+	if wait > 0 {
+		ws.recordError(webhook, "not_ready", "startup delay not over, answered 503", requestID)
+		c.Header("Retry-After", retryAfterSeconds(wait))
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":            "Service is starting up",
+			"ready_in_seconds": wait.Seconds(),
+		})
+		return
+	}
+	if gate := webhook.startup; gate != nil {
+		gate.announce.Do(func() {
+			logrus.WithFields(logrus.Fields{
+				"webhook_id": webhook.ID,
+				"ready_at":   gate.readyAt.Format(time.RFC3339),
+			}).Info("Webhook startup delay over, webhook is ready")
+		})
+	}
+
+	// Reject request URIs over max_uri_length before the breaker, the worker
+	// pool or the body are touched
+	if length, tooLong := webhook.uriTooLong(c.Request); tooLong {
+		ws.recordRequest(webhook, c.Request.Method)
+		webhook.Calculator.RecordURITooLong()
+		ws.calculator.RecordURITooLong()
+		ws.recordError(webhook, "uri_too_long", fmt.Sprintf("request URI is %d bytes, max_uri_length is %d", length, webhook.Config.MaxURILength), requestID)
+		if webhook.Config.EnableLogging {
+			logrus.WithFields(logrus.Fields{
+				"webhook_id": webhookID,
+				"request_id": requestID,
+				"webhook":    webhook.Name,
+				"uri_length": length,
+			}).Warn("Request URI exceeds max_uri_length")
+		}
+		c.JSON(http.StatusRequestURITooLong, webhook.uriTooLongBody(length))
+		return
+	}
+
+	// Open circuit: fail fast without the delay or the worker pool
+	if breaker := webhook.circuit; breaker != nil {
+		allowed, trial, retryAfter := breaker.allow(now)
+		if !allowed {
+			ws.recordRequest(webhook, c.Request.Method)
+			webhook.Calculator.RecordCircuitRejection()
+			c.Header("Retry-After", retryAfterSeconds(retryAfter))
+			c.JSON(breaker.statusCode, gin.H{
+				"error":         "Circuit breaker is open",
+				"circuit_state": breaker.current(now),
+			})
+			return
+		}
+		circuit, circuitTrial = breaker, trial
+	}
+
+	// Simulated worker pool: wait for a free slot, released on every exit path
+	if limiter := webhook.limiter; limiter != nil {
+		waited, err := limiter.acquire(c.Request.Context())
+		switch {
+		case err == errQueueFull:
+			webhook.Calculator.RecordQueueRejected()
+			ws.calculator.RecordQueueRejected()
+			ws.recordError(webhook, "queue_full", "max_queue is full, answered 503", requestID)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many concurrent requests, queue is full"})
+			return
+		case err != nil:
+			if clientCtx.Err() == nil {
+				ws.respondHandlerTimeout(webhook, c, requestID, now)
+				return
+			}
+			webhook.Calculator.RecordCancelled()
+			ws.calculator.RecordCancelled()
+			c.Abort()
+			return
+		}
+		defer limiter.release()
+		trace.mark("slot_acquired")
+		if waited > 0 {
+			webhook.Calculator.RecordQueueWait(waited)
+			ws.calculator.RecordQueueWait(waited)
+		}
+	}
+
+	// Track requests being processed; queued requests are not counted
+	concurrent := webhook.Calculator.beginRequest() - 1
+	defer webhook.Calculator.endRequest()
+	ws.calculator.beginRequest()
+	defer ws.calculator.endRequest()
+
+	// Body size for metrics: Content-Length, or the actual length once the body is read
+	bodySize := c.Request.ContentLength
+
+	// Read and log request body if logging is enabled
+	var requestBody string
+	var requestHeaders map[string][]string
+	if logDetails {
+		// Read request body
+		bodyBytes, err := peekRequestBody(c)
+		if err == nil {
+			requestBody = string(bodyBytes)
+			bodySize = int64(len(bodyBytes))
+		}
+
+		// Filter, redact and cap request headers
+		var headersTruncated bool
+		requestHeaders, headersTruncated = webhook.headerLog.apply(c.Request.Header)
+
+		// Log request details
+		fields := logrus.Fields{
+			"webhook_id":                webhookID,
+			"request_id":                requestID,
+			"method":                    c.Request.Method,
+			"path":                      c.Request.URL.Path,
+			"query_params":              c.Request.URL.RawQuery,
+			"ip":                        c.ClientIP(),
+			"user_agent":                c.GetHeader("User-Agent"),
+			"webhook":                   webhook.Name,
+			"request_headers":           requestHeaders,
+			"request_headers_truncated": headersTruncated,
+			"request_body":              requestBody,
+			"content_length":            c.Request.ContentLength,
+		}
+		// Only the configured fields of JSON bodies; other bodies are logged capped
+		if len(webhook.logBodyPaths) > 0 {
+			if extracted, ok := extractBodyFields(bodyBytes, webhook.logBodyPaths); ok {
+				delete(fields, "request_body")
+				fields["request_body_fields"] = extracted
+			} else if len(requestBody) > logBodyFallbackBytes {
+				fields["request_body"] = requestBody[:logBodyFallbackBytes]
+				fields["request_body_truncated"] = true
+			}
+		}
+		logrus.WithFields(fields).Info("Request received")
+	}
+
+	// Raw body capture for replay, independent of logging
+	if capture := webhook.capture; capture != nil {
+		if bodyBytes, err := peekRequestBody(c); err == nil {
+			captureRequestBody(capture, webhook, c, requestID, bodyBytes)
+			bodySize = int64(len(bodyBytes))
+		}
+	}
+
+	// Per-value request counts of a body field, independent of logging
+	if path := webhook.metricsLabel; path != nil {
+		if bodyBytes, err := peekRequestBody(c); err == nil {
+			webhook.Calculator.RecordLabel(metricsLabelValue(bodyBytes, *path), webhook.Config.MetricsLabelMaxValues)
+			bodySize = int64(len(bodyBytes))
+		}
+	}
+
+	// Bodies of unknown length (chunked, never read) are left out of the averages
+	if bodySize >= 0 {
+		webhook.Calculator.RecordBodySize(bodySize)
+		ws.calculator.RecordBodySize(bodySize)
+	}
+
+	// Reject requests that lack any of the required headers
+	if missing, status := webhook.missingHeaders(c); len(missing) > 0 {
+		ws.recordRequest(webhook, c.Request.Method)
+		webhook.Calculator.RecordMissingHeaders()
+		ws.calculator.RecordMissingHeaders()
+		ws.recordError(webhook, "missing_header", "missing required headers: "+strings.Join(missing, ", "), requestID)
+		if webhook.Config.EnableLogging {
+			logrus.WithFields(logrus.Fields{
+				"webhook_id":      webhookID,
+				"request_id":      requestID,
+				"webhook":         webhook.Name,
+				"missing_headers": missing,
+			}).Warn("Request is missing required headers")
+		}
+		c.JSON(status, gin.H{"error": "missing required headers", "missing_headers": missing})
+		return
+	}
+
+	// Reject requests without a valid HMAC signature
+	if reason := webhook.checkSignature(c); reason != "" {
+		ws.recordRequest(webhook, c.Request.Method)
+		webhook.Calculator.RecordSignatureFailure()
+		ws.calculator.RecordSignatureFailure()
+		ws.recordError(webhook, "signature_failure", reason, requestID)
+		if webhook.Config.EnableLogging {
+			logrus.WithFields(logrus.Fields{
+				"webhook_id": webhookID,
+				"request_id": requestID,
+				"webhook":    webhook.Name,
+				"error":      reason,
+			}).Warn("Request signature verification failed")
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature", "details": reason})
+		return
+	}
+
+	// Reject request bodies that don't match the configured JSON Schema
+	if webhook.requestSchema != nil {
+		if validationErr := validateRequestBody(webhook.requestSchema, c); validationErr != nil {
+			ws.recordRequest(webhook, c.Request.Method)
+			webhook.Calculator.RecordSchemaFailure()
+			ws.calculator.RecordSchemaFailure()
+			ws.recordError(webhook, "schema_failure", fmt.Sprintf("%v: %v", validationErr["error"], validationErr["details"]), requestID)
+			if webhook.Config.EnableLogging {
+				logrus.WithFields(logrus.Fields{
+					"webhook_id": webhookID,
+					"request_id": requestID,
+					"webhook":    webhook.Name,
+					"error":      validationErr["error"],
+				}).Warn("Request body failed schema validation")
+			}
+			c.JSON(http.StatusUnprocessableEntity, validationErr)
+			return
+		}
+	}
+
+	// Reject clients that keep sending the same body
+	if repeat := webhook.repeat; repeat != nil {
+		if bodyBytes, err := peekRequestBody(c); err == nil {
+			if streak, exceeded := repeat.observe(bodyBytes); exceeded {
+				ws.recordRequest(webhook, c.Request.Method)
+				webhook.Calculator.RecordRepeatFailure()
+				ws.calculator.RecordRepeatFailure()
+				ws.recordError(webhook, "repeat_failure", fmt.Sprintf("identical body received %d times in a row", streak), requestID)
+				if webhook.Config.EnableLogging {
+					logrus.WithFields(logrus.Fields{
+						"webhook_id": webhookID,
+						"request_id": requestID,
+						"webhook":    webhook.Name,
+						"repeats":    streak,
+					}).Warn("Identical request body repeated too many times")
+				}
+				c.JSON(repeat.statusCode, gin.H{"error": "Identical request body repeated", "repeats": streak})
+				return
+			}
+		}
+	}
+
+	// Work out the artificial delay for this request
+	baseTimeout := webhook.Config.Timeout
+	delaySource := "config"
+	if timeout, ok := webhook.Config.MethodTimeouts[c.Request.Method]; ok {
+		baseTimeout = timeout
+		delaySource = "method"
+	}
+	var delayRule string
+	if pattern := webhook.delayPattern; pattern != nil {
+		if patternDelay, rule, ok := pattern.next(); ok {
+			baseTimeout = int(patternDelay / time.Millisecond)
+			delaySource = "pattern"
+			delayRule = rule
+			webhook.Calculator.RecordPatternHit(rule)
+		}
+	}
+	var step *ResponseStep
+	stepIndex := -1
+	if sequence := webhook.sequence; sequence != nil {
+		next, index := sequence.next()
+		step, stepIndex = &next, index
+		if step.DelayMs != nil {
+			baseTimeout = *step.DelayMs
+			delaySource = "sequence"
+		}
+	}
+	if coldStart := webhook.coldStart; coldStart != nil && coldStart.hit(now) {
+		baseTimeout = int(coldStart.delay / time.Millisecond)
+		delaySource = "cold_start"
+		webhook.Calculator.RecordColdStart()
+		ws.calculator.RecordColdStart()
+	}
+	if webhook.Config.AllowClientDelay {
+		if clientDelay, ok := parseClientDelay(c.GetHeader("X-Delay-Ms"), webhook.Config.MaxClientDelayMs); ok {
+			baseTimeout = clientDelay
+			delaySource = "client"
+		}
+	}
+	delay := time.Duration(baseTimeout) * time.Millisecond
+	if webhook.Config.DelayDistribution != nil {
+		delay = time.Duration(webhook.Config.DelayDistribution.sample(webhook.rand, baseTimeout) * float64(time.Millisecond))
+	}
+	if webhook.backoff != nil {
+		delay += webhook.backoff.hit(c.ClientIP(), now)
+	}
+	var sizeDelay time.Duration
+	if webhook.Config.DelayPerKB > 0 {
+		// Chunked bodies have no Content-Length, so read them to measure
+		size := bodySize
+		if size < 0 {
+			if bodyBytes, err := peekRequestBody(c); err == nil {
+				size = int64(len(bodyBytes))
+			}
+		}
+		sizeDelay = bodyDelay(webhook.Config, size)
+		delay += sizeDelay
+		webhook.Calculator.RecordBodyDelay(sizeDelay)
+	}
+	if webhook.Config.ConcurrencyLatencyFactor > 0 && concurrent > 0 {
+		delay += time.Duration(webhook.Config.ConcurrencyLatencyFactor * float64(concurrent) * float64(time.Millisecond))
+	}
+
+	// Guard against misconfigured multi-minute sleeps: skip the delay and answer right away
+	if ws.maxEffectiveDelay > 0 && delay > ws.maxEffectiveDelay {
+		ws.recordRequest(webhook, c.Request.Method)
+		webhook.Calculator.RecordDelaySkipped()
+		ws.calculator.RecordDelaySkipped()
+		ws.recordError(webhook, "delay_skipped", fmt.Sprintf("delay %s exceeds max_effective_delay %s", delay, ws.maxEffectiveDelay), requestID)
+		logrus.WithFields(logrus.Fields{
+			"webhook_id":          webhookID,
+			"request_id":          requestID,
+			"webhook":             webhook.Name,
+			"delay":               delay.String(),
+			"max_effective_delay": ws.maxEffectiveDelay.String(),
+		}).Warn("Delay exceeds max_effective_delay, skipped")
+		c.Header("X-Delay-Skipped", "true")
+		c.JSON(ws.skippedDelayStatus, gin.H{
+			"message":                "Delay skipped",
+			"delay_ms":               delay.Milliseconds(),
+			"max_effective_delay_ms": ws.maxEffectiveDelay.Milliseconds(),
+		})
+		return
+	}
+
+	// Apply delay if configured, aborting early if the client goes away
+	if delay > 0 {
+		trace.mark("delay_start")
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+			trace.mark("delay_end")
+		case <-c.Request.Context().Done():
+			timer.Stop()
+			if clientCtx.Err() == nil {
+				ws.respondHandlerTimeout(webhook, c, requestID, now)
+				return
+			}
+			webhook.Calculator.RecordCancelled()
+			ws.calculator.RecordCancelled()
+			if webhook.Config.EnableLogging {
+				logrus.WithFields(logrus.Fields{
+					"webhook_id": webhookID,
+					"request_id": requestID,
+					"webhook":    webhook.Name,
+					"elapsed":    time.Since(now).String(),
+				}).Warn("Client disconnected before response was sent")
+			}
+			c.Abort()
+			return
+		}
+	}
+
+	// Record request for metrics (only requests that reach the response stage count)
+	ws.recordRequest(webhook, c.Request.Method)
+
+	// Retry-until-success testing: the first fail_first_n requests since the last reset get 503
+	if n := webhook.Config.FailFirstN; n > 0 {
+		if attempt, fail := webhook.Calculator.ClaimFailFirst(n); fail {
+			if logDetails {
+				logrus.WithFields(logrus.Fields{
+					"webhook_id": webhookID,
+					"request_id": requestID,
+					"webhook":    webhook.Name,
+					"attempt":    attempt,
+				}).Info("Failing request by design (fail_first_n)")
+			}
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":        "Failing by design",
+				"attempt":      attempt,
+				"fail_first_n": n,
+			})
+			ws.recordLatency(webhook, c.Request.Method, time.Since(now), delay)
+			return
+		}
+	}
+
+	// Proxy mode: relay the upstream response instead of the configured one
+	if webhook.Config.ForwardTo != "" {
+		ws.forwardRequest(webhook, c, requestID, logDetails, now)
+		ws.recordLatency(webhook, c.Request.Method, time.Since(now), delay)
+		return
+	}
+
+	// Set custom headers
+	responseHeaders := make(map[string]string)
+	for key, value := range webhook.Config.Headers {
+		c.Header(key, value)
+		responseHeaders[key] = value
+	}
+	if step != nil {
+		for key, value := range step.Headers {
+			c.Header(key, value)
+			responseHeaders[key] = value
+		}
+		c.Header("X-Sequence-Step", strconv.Itoa(stepIndex))
+		responseHeaders["X-Sequence-Step"] = strconv.Itoa(stepIndex)
+	}
+
+	// Produce the response body; a body that can't be rendered is a 500, never a partial body
+	statusCode := webhook.Config.StatusCode
+	contentType := webhook.Config.ContentType
+	var responseBody string
+	var err error
+	if step != nil && step.StatusCode != 0 {
+		statusCode = step.StatusCode
+	}
+	queryBody, queryFound := "", false
+	if webhook.Config.QueryResponseParam != "" {
+		queryBody, queryFound = webhook.queryResponse(c)
+	}
+	if step != nil && step.ResponseBody != "" {
+		responseBody = step.ResponseBody
+	} else if queryFound {
+		responseBody = queryBody
+	} else if webhook.Config.QueryResponseParam != "" && webhook.Config.QueryResponseNotFound {
+		c.JSON(http.StatusNotFound, webhook.queryNotFound(c))
+		return
+	} else if split := webhook.abSplit; split != nil {
+		variant := split.pick(c)
+		webhook.Calculator.RecordVariant(variant.Name)
+		c.Header(split.header, variant.Name)
+		responseHeaders[split.header] = variant.Name
+		responseBody = variant.ResponseBody
+		if variant.StatusCode != 0 && (step == nil || step.StatusCode == 0) {
+			statusCode = variant.StatusCode
+		}
+		if variant.ContentType != "" {
+			contentType = variant.ContentType
+		}
+	} else if rotation := webhook.bodyRotation; rotation != nil {
+		entry := webhook.Config.ResponseBodyList[rotation.next(len(webhook.Config.ResponseBodyList))]
+		responseBody = entry.Body
+		if entry.StatusCode != 0 && (step == nil || step.StatusCode == 0) {
+			statusCode = entry.StatusCode
+		}
+		if entry.ContentType != "" {
+			contentType = entry.ContentType
+		}
+	} else {
+		responseBody, err = webhook.renderResponseBody(c)
+	}
+	if handlerTimedOut(c) {
+		ws.respondHandlerTimeout(webhook, c, requestID, now)
+		return
+	}
+	if err != nil {
+		webhook.Calculator.RecordRenderError()
+		ws.calculator.RecordRenderError()
+		ws.recordError(webhook, "render_error", err.Error(), requestID)
+		logrus.WithFields(logrus.Fields{
+			"webhook_id": webhookID,
+			"request_id": requestID,
+			"webhook":    webhook.Name,
+			"error":      err.Error(),
+		}).Error("Failed to render response body")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Response body could not be rendered",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Set content type and prepare response
+	c.Header("Content-Type", contentType)
+	responseHeaders["Content-Type"] = contentType
+
+	// Pretty-print JSON for client debuggers; the logged body stays compact
+	sentBody := responseBody
+	if webhook.Config.PrettyJSON && strings.Contains(contentType, "json") {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, []byte(responseBody), "", "  "); err != nil {
+			ws.recordError(webhook, "pretty_json", "response body is not valid JSON: "+err.Error(), requestID)
+			logrus.WithFields(logrus.Fields{
+				"webhook_id": webhookID,
+				"webhook":    webhook.Name,
+				"error":      err.Error(),
+			}).Warn("Response body is not valid JSON, sending it as-is")
+		} else {
+			sentBody = indented.String()
+		}
+	}
+
+	if webhook.Config.CacheControl != "" {
+		c.Header("Cache-Control", webhook.Config.CacheControl)
+		responseHeaders["Cache-Control"] = webhook.Config.CacheControl
+	}
+
+	// Top up to the latency floor; the wait counts as intentional delay
+	var floorWait time.Duration
+	if floor := time.Duration(webhook.Config.MinLatencyMs) * time.Millisecond; floor > 0 {
+		if floorWait = floor - time.Since(now); floorWait > 0 {
+			trace.mark("latency_floor_start")
+			timer := time.NewTimer(floorWait)
+			select {
+			case <-timer.C:
+				trace.mark("latency_floor_end")
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				if handlerTimedOut(c) {
+					ws.respondHandlerTimeout(webhook, c, requestID, now)
+					return
+				}
+				webhook.Calculator.RecordCancelled()
+				ws.calculator.RecordCancelled()
+				c.Abort()
+				return
+			}
+			delay += floorWait
+		} else {
+			floorWait = 0
+		}
+	}
+
+	if webhook.Config.ServerTiming {
+		timing := serverTiming(delay, time.Since(now)-delay)
+		c.Header("Server-Timing", timing)
+		responseHeaders["Server-Timing"] = timing
+	}
+
+	// Sign the body exactly as sent
+	if signing := webhook.responseSigning; signing != nil {
+		signature := signing.sign([]byte(sentBody))
+		c.Header(signing.Header, signature)
+		responseHeaders[signing.Header] = signature
+	}
+
+	// Conditional requests: 304 without a body when the client's copy is current
+	if webhook.Config.ETag {
+		etag := webhook.etag
+		if etag == "" {
+			etag = bodyETag(responseBody)
+		}
+		c.Header("ETag", etag)
+		responseHeaders["ETag"] = etag
+		if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+			webhook.Calculator.RecordNotModified()
+			ws.calculator.RecordNotModified()
+			c.Status(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			ws.recordLatency(webhook, c.Request.Method, time.Since(now), delay)
+			return
+		}
+	}
+
+	// Send response
+	if webhook.Config.WriteDelayPerChunk > 0 {
+		if writeBodySlowly(c, statusCode, sentBody, webhook.Config) == context.DeadlineExceeded {
+			ws.respondHandlerTimeout(webhook, c, requestID, now)
+			return
+		}
+	} else if webhook.Config.ForceChunked {
+		webhook.Calculator.RecordChunkedResponse()
+		ws.calculator.RecordChunkedResponse()
+		writeBodyChunked(c, statusCode, sentBody, webhook.Config.WriteChunkSize)
+	} else {
+		if webhook.Config.LingerMs > 0 {
+			// Announce the length so the client sees a complete response while we linger
+			c.Header("Content-Length", strconv.Itoa(len(sentBody)))
+		}
+		c.String(statusCode, sentBody)
+	}
+	trace.mark("response_written")
+
+	ws.recordLatency(webhook, c.Request.Method, time.Since(now), delay)
+
+	// Log response details if logging is enabled
+	if logDetails {
+		logrus.WithFields(logrus.Fields{
+			"webhook_id":       webhookID,
+			"request_id":       requestID,
+			"webhook":          webhook.Name,
+			"response_status":  statusCode,
+			"response_headers": responseHeaders,
+			"response_body":    responseBody,
+			"method":           c.Request.Method,
+			"delay":            delay.String(),
+			"delay_source":     delaySource,
+			"delay_rule":       delayRule,
+			"body_delay":       sizeDelay.String(),
+			"latency_floor":    floorWait.String(),
+			"processing_time":  time.Since(now).String(),
+		}).Info("Response sent")
+	}
+
+	// Linger after the response is flushed; not counted in latency metrics
+	if webhook.Config.LingerMs > 0 {
+		c.Writer.Flush()
+		timer := time.NewTimer(time.Duration(webhook.Config.LingerMs) * time.Millisecond)
+		select {
+		case <-timer.C:
+		case <-c.Request.Context().Done():
+			timer.Stop()
+		}
+	}
+}
+
+// serverTiming formats a Server-Timing header value, durations in milliseconds
+// to one decimal, e.g. "delay;dur=2000, handler;dur=1.2"
+func serverTiming(delay, handler time.Duration) string {
+	ms := func(d time.Duration) string {
+		return strconv.FormatFloat(math.Round(float64(d)/float64(time.Millisecond)*10)/10, 'f', -1, 64)
+	}
+	return "delay;dur=" + ms(delay) + ", handler;dur=" + ms(handler)
+}
+
+// forwardRequest proxies the request (method, headers, body, query) to the webhook's
+// upstream and relays the response. Upstream failures are answered with 502,
+// a handler_timeout firing meanwhile with 504.
+func (ws *WebhookServer) forwardRequest(webhook *Webhook, c *gin.Context, requestID string, logDetails bool, started time.Time) {
+	timeout := time.Duration(webhook.Config.ForwardTimeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	target := webhook.Config.ForwardTo
+	if c.Request.URL.RawQuery != "" {
+		separator := "?"
+		if strings.Contains(target, "?") {
+			separator = "&"
+		}
+		target += separator + c.Request.URL.RawQuery
+	}
+
+	upstreamStart := time.Now()
+	response, err := func() (*http.Response, error) {
+		request, err := http.NewRequestWithContext(ctx, c.Request.Method, target, c.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		request.Header = c.Request.Header.Clone()
+		for _, header := range hopByHopHeaders {
+			request.Header.Del(header)
+		}
+		request.ContentLength = c.Request.ContentLength
+		return forwardClient.Do(request)
+	}()
+	upstreamLatency := time.Since(upstreamStart)
+
+	// The handler deadline firing is a timeout, not an upstream failure
+	if err != nil && handlerTimedOut(c) {
+		ws.respondHandlerTimeout(webhook, c, requestID, started)
+		return
+	}
+	if err != nil {
+		webhook.Calculator.RecordUpstream(upstreamLatency, false)
+		ws.calculator.RecordUpstream(upstreamLatency, false)
+		ws.recordError(webhook, "upstream_error", err.Error(), requestID)
+		logrus.WithFields(logrus.Fields{
+			"webhook_id": webhook.ID,
+			"request_id": requestID,
+			"webhook":    webhook.Name,
+			"upstream":   webhook.Config.ForwardTo,
+			"error":      err.Error(),
+		}).Error("Upstream request failed")
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Upstream request failed",
+			"details": err.Error(),
+		})
+		return
+	}
+	defer response.Body.Close()
+
+	webhook.Calculator.RecordUpstream(upstreamLatency, true)
+	ws.calculator.RecordUpstream(upstreamLatency, true)
+
+	for key, values := range response.Header {
+		for _, value := range values {
+			c.Writer.Header().Add(key, value)
+		}
+	}
+	for _, header := range hopByHopHeaders {
+		c.Writer.Header().Del(header)
+	}
+	c.Status(response.StatusCode)
+	if _, err := io.Copy(c.Writer, response.Body); err != nil && handlerTimedOut(c) {
+		ws.respondHandlerTimeout(webhook, c, requestID, started)
+		return
+	} else if err != nil {
+		ws.recordError(webhook, "upstream_relay", err.Error(), requestID)
+		logrus.WithFields(logrus.Fields{
+			"webhook_id": webhook.ID,
+			"request_id": requestID,
+			"error":      err.Error(),
+		}).Warn("Failed to relay upstream response body")
+	}
+
+	if logDetails {
+		logrus.WithFields(logrus.Fields{
+			"webhook_id":       webhook.ID,
+			"request_id":       requestID,
+			"webhook":          webhook.Name,
+			"upstream":         webhook.Config.ForwardTo,
+			"response_status":  response.StatusCode,
+			"upstream_latency": upstreamLatency.String(),
+		}).Info("Response relayed from upstream")
+	}
+}
+
+// handlerTimedOut reports whether the request's handler_timeout deadline has fired
+func handlerTimedOut(c *gin.Context) bool {
+	return c.Request.Context().Err() == context.DeadlineExceeded
+}
+
+// respondHandlerTimeout answers 504 when a webhook exceeds its handler_timeout.
+// If part of the response was already sent, the response is cut off instead.
+func (ws *WebhookServer) respondHandlerTimeout(webhook *Webhook, c *gin.Context, requestID string, started time.Time) {
+	webhook.Calculator.RecordTimeoutError()
+	ws.calculator.RecordTimeoutError()
+	ws.recordError(webhook, "handler_timeout", fmt.Sprintf("exceeded handler_timeout of %dms after %s", webhook.Config.HandlerTimeout, time.Since(started)), requestID)
+	logrus.WithFields(logrus.Fields{
+		"webhook_id":      webhook.ID,
+		"request_id":      requestID,
+		"webhook":         webhook.Name,
+		"handler_timeout": webhook.Config.HandlerTimeout,
+		"elapsed":         time.Since(started).String(),
+	}).Warn("Handler timeout exceeded")
+	if c.Writer.Written() {
+		c.Abort()
+		return
+	}
+	c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Handler timeout exceeded"})
+}
+
+// defaultMaxBodyDelayMs caps the delay_per_kb delay when max_body_delay_ms is not set
+const defaultMaxBodyDelayMs = 10000
+
+// bodyDelay returns the delay_per_kb delay for a body of size bytes
+func bodyDelay(config WebhookConfig, size int64) time.Duration {
+	if config.DelayPerKB <= 0 || size <= 0 {
+		return 0
+	}
+	maxMs := config.MaxBodyDelayMs
+	if maxMs <= 0 {
+		maxMs = defaultMaxBodyDelayMs
+	}
+	delay := time.Duration(size) * time.Duration(config.DelayPerKB) * time.Millisecond / 1024
+	if limit := time.Duration(maxMs) * time.Millisecond; delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// defaultMaxClientDelayMs caps X-Delay-Ms when max_client_delay_ms is not set
+const defaultMaxClientDelayMs = 10000
+
+// parseClientDelay reads an X-Delay-Ms value clamped to [0, maxMs]. Missing or
+// malformed values are ignored (ok is false) so the configured delay applies.
+func parseClientDelay(value string, maxMs int) (int, bool) {
+	if value == "" {
+		return 0, false
+	}
+	delay, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		logrus.Debugf("Ignoring malformed X-Delay-Ms header %q", value)
+		return 0, false
+	}
+	if maxMs <= 0 {
+		maxMs = defaultMaxClientDelayMs
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > maxMs {
+		delay = maxMs
+	}
+	return delay, true
+}
+
+// recordRequest counts a request on the webhook and on the server-wide calculator
+func (ws *WebhookServer) recordRequest(webhook *Webhook, method string) {
+	webhook.Calculator.RecordRequest()
+	webhook.Calculator.RecordMethod(method)
+	ws.calculator.RecordRequest()
+	ws.calculator.RecordMethod(method)
+
+	// Fan-out targets count the request too; the server total counts it once.
+	// Targets deleted since the config was validated are skipped.
+	for _, id := range webhook.Config.FanOutTo {
+		if target, exists := ws.getWebhook(id); exists {
+			target.Calculator.RecordRequest()
+			target.Calculator.RecordMethod(method)
+			target.Calculator.RecordFanIn()
+		}
+	}
+}
+
+// recordLatency records a completed request's latency on the webhook and server calculators
+// and feeds the Prometheus histograms, which split off the intentional delay
+func (ws *WebhookServer) recordLatency(webhook *Webhook, method string, latency, delay time.Duration) {
+	webhook.Calculator.RecordLatency(method, latency)
+	ws.calculator.RecordLatency(method, latency)
+	webhook.Calculator.RecordDelay(delay)
+	ws.calculator.RecordDelay(delay)
+	// Budgets are per webhook, so the server-wide calculator doesn't track them
+	if budget := webhook.Config.DeadlineBudgetMs; budget > 0 {
+		webhook.Calculator.RecordDeadline(latency, time.Duration(budget)*time.Millisecond)
+	}
+
+	if webhook.Calculator.IsPaused() {
+		return
+	}
+	histograms := ws.webhookHistograms(webhook.ID)
+	histograms.processing.observe((latency - delay).Seconds())
+	histograms.delay.observe(delay.Seconds())
+}
+
+// sampleLog decides whether a request should be logged in full for the given sample rate
+func sampleLog(rnd *webhookRand, rate float64) bool {
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+	return rnd.Float64() < rate
+}
+
+// validateRequestBody checks the request body against a JSON Schema and returns
+// an error response body, or nil if the body is valid. The body is restored
+// so it can be read again afterwards.
+func validateRequestBody(schema *jsonschema.Schema, c *gin.Context) gin.H {
+	bodyBytes, err := peekRequestBody(c)
+	if err != nil {
+		return gin.H{"error": "Could not read request body", "details": err.Error()}
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(bodyBytes, &payload); err != nil {
+		return gin.H{"error": "Request body is not valid JSON", "details": err.Error()}
+	}
+
+	if err := schema.Validate(payload); err != nil {
+		var validationErr *jsonschema.ValidationError
+		if errors.As(err, &validationErr) {
+			return gin.H{"error": "Request body does not match schema", "details": validationErr.BasicOutput().Errors}
+		}
+		return gin.H{"error": "Request body does not match schema", "details": err.Error()}
+	}
+	return nil
+}
+
+// writeBodySlowly writes the response body in small chunks, sleeping between
+// flushes to simulate a server that accepts fast but writes slowly. It stops
+// as soon as the client disconnects or the handler deadline fires, returning
+// the context error.
+func writeBodySlowly(c *gin.Context, statusCode int, body string, config WebhookConfig) error {
+	chunkSize := config.WriteChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 1
+	}
+	delay := time.Duration(config.WriteDelayPerChunk) * time.Millisecond
+	ctx := c.Request.Context()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.Status(statusCode)
+	for offset := 0; offset < len(body); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(body) {
+			end = len(body)
+		}
+		if _, err := c.Writer.WriteString(body[offset:end]); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+
+		if end == len(body) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return nil
+}
+
+// writeBodyChunked writes the response body without a Content-Length, flushing
+// after every chunk so net/http falls back to chunked transfer encoding. A
+// chunkSize of 0 or less uses 1024 bytes. HTTP/1.0 clients get the body
+// unframed and the connection is closed instead.
+func writeBodyChunked(c *gin.Context, statusCode int, body string, chunkSize int) {
+	if chunkSize <= 0 {
+		chunkSize = 1024
+	}
+	c.Writer.Header().Del("Content-Length")
+	c.Status(statusCode)
+	// Commit the headers before any body bytes so the length is never inferred
+	c.Writer.Flush()
+	for offset := 0; offset < len(body); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(body) {
+			end = len(body)
+		}
+		if _, err := c.Writer.WriteString(body[offset:end]); err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// getWebhook returns a snapshot of the webhook copied under the lock. Updates
+// replace Config and the state compiled from it under ws.mu, so the copy stays
+// consistent; the trackers it points to are shared and synchronize themselves.
+func (ws *WebhookServer) getWebhook(id string) (*Webhook, bool) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	webhook, exists := ws.webhooks[id]
+	return snapshotWebhook(webhook), exists
+}
+
+// touchWebhook records a request's arrival and returns a snapshot to serve it from
+func (ws *WebhookServer) touchWebhook(id string, now time.Time) (*Webhook, bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	webhook, exists := ws.webhooks[id]
+	if webhook != nil {
+		webhook.LastRequest = &now
+	}
+	return snapshotWebhook(webhook), exists
+}
+
+// getAllWebhooks returns snapshots of every webhook, see getWebhook
+func (ws *WebhookServer) getAllWebhooks() []*Webhook {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	webhooks := make([]*Webhook, 0, len(ws.webhooks))
+	for _, webhook := range ws.webhooks {
+		webhooks = append(webhooks, snapshotWebhook(webhook))
+	}
+	return webhooks
+}
+
+// snapshotWebhook copies a webhook; callers must hold ws.mu
+func snapshotWebhook(webhook *Webhook) *Webhook {
+	if webhook == nil {
+		return nil
+	}
+	snapshot := *webhook
+	return &snapshot
+}
+
+// RouteInfo describes a live route for the /api/routes endpoint
+type RouteInfo struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Type        string `json:"type"` // "webhook" or "management"
+	WebhookID   string `json:"webhook_id,omitempty"`
+	WebhookName string `json:"webhook_name,omitempty"`
+}
+
+// embeddedStatic is the web interface compiled into the binary
+//
+//go:embed static
+var embeddedStatic embed.FS
+
+// staticDir is the on-disk web interface, preferred during development
+const staticDir = "./static"
+
+// staticFileSystem returns the file system served under /static for the
+// static_source setting: "embedded", "disk", or "auto"/"" for the disk folder
+// when it exists and the embedded copy otherwise.
+func staticFileSystem(source string) (http.FileSystem, error) {
+	switch source {
+	case "", "auto":
+		if info, err := os.Stat(staticDir); err == nil && info.IsDir() {
+			logrus.Infof("Serving the web interface from %s", staticDir)
+			return gin.Dir(staticDir, false), nil
+		}
+	case "disk":
+		logrus.Infof("Serving the web interface from %s", staticDir)
+		return gin.Dir(staticDir, false), nil
+	case "embedded":
+	default:
+		return nil, fmt.Errorf("unknown value %q (use auto, embedded or disk)", source)
+	}
+
+	files, err := fs.Sub(embeddedStatic, "static")
+	if err != nil {
+		return nil, err
+	}
+	logrus.Info("Serving the embedded web interface")
+	return http.FS(files), nil
+}
+
+// getRoutes lists all webhook paths plus the static management routes, sorted by path
+func (ws *WebhookServer) getRoutes() []RouteInfo {
+	ws.mu.RLock()
+	routes := make([]RouteInfo, 0, len(ws.webhooks))
+	webhookPaths := make(map[string]bool, len(ws.webhooks))
+	for _, webhook := range ws.webhooks {
+		routes = append(routes, RouteInfo{
+			Method:      "ANY",
+			Path:        webhook.Path,
+			Type:        "webhook",
+			WebhookID:   webhook.ID,
+			WebhookName: webhook.Name,
+		})
+		webhookPaths[webhook.Path] = true
+	}
+	ws.mu.RUnlock()
+
+	for _, route := range ws.router.Routes() {
+		// Webhook routes are registered for every method; they are already listed above
+		if webhookPaths[route.Path] {
+			continue
+		}
+		routes = append(routes, RouteInfo{
+			Method: route.Method,
+			Path:   route.Path,
+			Type:   "management",
+		})
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+func (ws *WebhookServer) deleteWebhook(id string) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	// Don't allow deleting default webhooks
+	if id == "default" || id == "fast" || id == "slow" {
+		return false
+	}
+
+	if webhook, exists := ws.webhooks[id]; exists {
+		ws.removeWebhookLocked(webhook)
+		return true
+	}
+	return false
+}
+
+func (t *TPSCalculator) RecordRequest() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	now := time.Now()
+
+	// Time spent paused doesn't count towards the gap or the TPS duration
+	pause := t.pendingPause
+	t.pendingPause = 0
+
+	if !t.isActive {
+		t.startTime = now
+		t.isActive = true
+	} else {
+		t.pausedTotal += pause
+		interval := now.Sub(t.lastTime) - pause
+		if !t.hasInterval || interval < t.minInterval {
+			t.minInterval = interval
+		}
+		if !t.hasInterval || interval > t.maxInterval {
+			t.maxInterval = interval
+		}
+		t.hasInterval = true
+	}
+
+	t.requestCount++
+	t.lastTime = now
+
+	t.bucketLocked(now.Unix()).Count++
+}
+
+// peakLocked returns the most requests seen in a single retained second;
+// the caller must hold t.mu
+func (t *TPSCalculator) peakLocked() int64 {
+	var peak int64
+	for _, bucket := range t.buckets {
+		if bucket.Count > peak {
+			peak = bucket.Count
+		}
+	}
+	return peak
+}
+
+// bucketLocked returns the per-second bucket for a unix second, lazily
+// allocating the ring and clearing slots as it wraps; the caller must hold t.mu
+func (t *TPSCalculator) bucketLocked(second int64) *secondBucket {
+	if t.buckets == nil {
+		t.buckets = make([]secondBucket, bucketRetentionSeconds)
+	}
+	bucket := &t.buckets[second%bucketRetentionSeconds]
+	if bucket.Second != second {
+		*bucket = secondBucket{Second: second}
+	}
+	return bucket
+}
+
+// Histogram returns request counts from `from` to `to` (inclusive, truncated to
+// whole seconds) aggregated into buckets of `resolution` seconds. Seconds older
+// than the retention window are not included.
+func (t *TPSCalculator) Histogram(from, to time.Time, resolution int64) []HistogramPoint {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if resolution < 1 {
+		resolution = 1
+	}
+	fromSecond := from.Unix()
+	toSecond := to.Unix()
+	if oldest := toSecond - bucketRetentionSeconds + 1; fromSecond < oldest {
+		fromSecond = oldest
+	}
+	if toSecond < fromSecond {
+		return []HistogramPoint{}
+	}
+
+	points := make([]HistogramPoint, 0, (toSecond-fromSecond)/resolution+1)
+	for start := fromSecond; start <= toSecond; start += resolution {
+		var count int64
+		for second := start; second < start+resolution && second <= toSecond; second++ {
+			count += t.countAtLocked(second)
+		}
+		points = append(points, HistogramPoint{
+			Time:  time.Unix(start, 0).UTC().Format(time.RFC3339),
+			Count: count,
+		})
+	}
+	return points
+}
+
+// RangeStats sums the per-second counts from `from` to `to` (inclusive, whole
+// seconds) and finds the busiest second. The caller keeps the range within retention.
+func (t *TPSCalculator) RangeStats(from, to time.Time) (total, peak int64, peakAt time.Time) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for second := from.Unix(); second <= to.Unix(); second++ {
+		count := t.countAtLocked(second)
+		total += count
+		if count > peak {
+			peak = count
+			peakAt = time.Unix(second, 0)
+		}
+	}
+	return total, peak, peakAt
+}
+
+// RecentSeconds returns the per-second request counts of the last n seconds,
+// oldest first and ending with the current second
+func (t *TPSCalculator) RecentSeconds(n int) []int64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if n > bucketRetentionSeconds {
+		n = bucketRetentionSeconds
+	}
+	now := time.Now().Unix()
+	counts := make([]int64, n)
+	for i := range counts {
+		counts[i] = t.countAtLocked(now - int64(n-1-i))
+	}
+	return counts
+}
+
+// RecentActivity returns the requests and 5xx responses of the last n seconds
+func (t *TPSCalculator) RecentActivity(n int) (requests, serverErrors int64) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if n > bucketRetentionSeconds {
+		n = bucketRetentionSeconds
+	}
+	if t.buckets == nil {
+		return 0, 0
+	}
+	now := time.Now().Unix()
+	for second := now - int64(n-1); second <= now; second++ {
+		if second < 0 {
+			continue
+		}
+		bucket := t.buckets[second%bucketRetentionSeconds]
+		if bucket.Second == second {
+			requests += bucket.Count
+			serverErrors += bucket.ServerErrors
+		}
+	}
+	return requests, serverErrors
+}
+
+// countAtLocked returns the request count for a unix second; the caller must hold t.mu
+func (t *TPSCalculator) countAtLocked(second int64) int64 {
+	if t.buckets == nil || second < 0 {
+		return 0
+	}
+	bucket := t.buckets[second%bucketRetentionSeconds]
+	if bucket.Second != second {
+		return 0
+	}
+	return bucket.Count
+}
+
+// StartTime returns when the first request was recorded, or the zero time if none
+func (t *TPSCalculator) StartTime() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.startTime
+}
+
+// RecordCancelled counts a request whose client disconnected before completion
+func (t *TPSCalculator) RecordCancelled() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.cancelled++
+}
+
+// RecordSchemaFailure counts a request rejected by request schema validation
+func (t *TPSCalculator) RecordSchemaFailure() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.schemaFails++
+}
+
+// RecordSignatureFailure counts a request rejected by HMAC signature verification
+func (t *TPSCalculator) RecordSignatureFailure() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.signatureFails++
+}
+
+// RecordURITooLong counts a request rejected with 414 for exceeding max_uri_length
+func (t *TPSCalculator) RecordURITooLong() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.uriTooLong++
+}
+
+// RecordMissingHeaders counts a request rejected for lacking a required header
+func (t *TPSCalculator) RecordMissingHeaders() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.missingHeaders++
+}
+
+// RecordFanIn counts a request recorded on behalf of another webhook's fan_out_to
+func (t *TPSCalculator) RecordFanIn() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.fanIn++
+}
+
+// RecordRepeatFailure counts a request rejected by fail_on_repeat
+func (t *TPSCalculator) RecordRepeatFailure() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.repeatFails++
+}
+
+// RecordPatternHit counts a request whose delay came from a delay_pattern rule
+func (t *TPSCalculator) RecordPatternHit(rule string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.patternHits[rule]++
+}
+
+// RecordVariant counts a request served by an ab_split variant
+func (t *TPSCalculator) RecordVariant(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.variantCounts[name]++
+}
+
+// RecordLabel counts a request under its metrics label value. Once maxValues
+// distinct values are tracked (0 = the default), new ones count as "other".
+func (t *TPSCalculator) RecordLabel(value string, maxValues int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	if maxValues <= 0 {
+		maxValues = defaultMetricsLabelMaxValues
+	}
+	if _, seen := t.labelCounts[value]; !seen && (len(value) > maxMetricsLabelLength || len(t.labelCounts) >= maxValues) {
+		value = labelOther
+	}
+	t.labelCounts[value]++
+}
+
+// RecordCircuitRejection counts a request answered fast by an open circuit breaker
+func (t *TPSCalculator) RecordCircuitRejection() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.circuitRejections++
+}
+
+// RecordCircuitOpen counts a circuit breaker opening
+func (t *TPSCalculator) RecordCircuitOpen() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.circuitOpens++
+}
+
+// RecordColdStart counts a request that got the cold_start delay
+func (t *TPSCalculator) RecordColdStart() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.coldStarts++
+}
+
+// RecordLatency stores a completed request's latency for percentile and
+// per-method reporting
+func (t *TPSCalculator) RecordLatency(method string, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	stats := t.methodTimes[method]
+	if stats == nil {
+		stats = &methodLatency{}
+		t.methodTimes[method] = stats
+	}
+	stats.count++
+	stats.total += latency
+
+	t.latencies = recordSample(t.latencies, &t.latencyNext, latency)
+}
+
+// RecordDelay stores the artificial delay a request actually waited
+func (t *TPSCalculator) RecordDelay(delay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.delays = recordSample(t.delays, &t.delayNext, delay)
+}
+
+// recordSample adds value to a ring buffer of latencySampleSize samples
+func recordSample[T any](samples []T, next *int, value T) []T {
+	if len(samples) < latencySampleSize {
+		return append(samples, value)
+	}
+	samples[*next] = value
+	*next = (*next + 1) % latencySampleSize
+	return samples
+}
+
+// RecordDeadline stores the share of the deadline budget a request used and
+// counts it as exceeded when it took longer than the budget
+func (t *TPSCalculator) RecordDeadline(latency, budget time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	utilization := float64(latency) / float64(budget)
+	t.deadlineUse = recordSample(t.deadlineUse, &t.deadlineNext, utilization)
+	if latency > budget {
+		t.deadlineExceeded++
+	}
+}
+
+// RecordClient feeds a client IP to the distinct-client estimate
+func (t *TPSCalculator) RecordClient(ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	if t.clientSketch == nil {
+		t.clientSketch = &hyperLogLog{}
+	}
+	t.clientSketch.add(ip)
+}
+
+// hllPrecision sets the HyperLogLog register count to 2^hllPrecision: 4096
+// one-byte registers, for a standard error of about 1.6% at any cardinality
+const hllPrecision = 12
+
+// hyperLogLog estimates the number of distinct strings in constant memory
+type hyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+// hashKey hashes a string such as a client IP to 64 well-spread bits
+func hashKey(value string) uint64 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(value))
+	// FNV spreads short similar strings like IPs poorly; finish with a 64-bit mixer
+	x := hasher.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+func (h *hyperLogLog) add(value string) {
+	x := hashKey(value)
+	index := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+// estimate returns the approximate distinct count, using linear counting
+// while many registers are still empty
+func (h *hyperLogLog) estimate() int64 {
+	const m = float64(1 << hllPrecision)
+	var sum float64
+	var zeros int
+	for _, register := range h.registers {
+		sum += math.Ldexp(1, -int(register))
+		if register == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(estimate))
+}
+
+// beginRequest marks a request as in flight and returns the new in-flight count
+func (t *TPSCalculator) beginRequest() int64 {
+	return t.inFlight.Add(1)
+}
+
+// endRequest marks an in-flight request as finished
+func (t *TPSCalculator) endRequest() {
+	t.inFlight.Add(-1)
+}
+
+// defaultPercentiles are the latency percentiles reported when metrics.percentiles is not set
+var defaultPercentiles = []float64{50, 95, 99}
+
+// percentilesOrDefault validates configured percentiles, falling back to the defaults
+func percentilesOrDefault(percentiles []float64) []float64 {
+	if len(percentiles) == 0 {
+		return defaultPercentiles
+	}
+	for _, p := range percentiles {
+		if p <= 0 || p >= 100 {
+			logrus.Warnf("Ignoring metrics.percentiles %v: each percentile must be between 0 and 100 (exclusive)", percentiles)
+			return defaultPercentiles
+		}
+	}
+	return percentiles
+}
+
+// defaultTPSPrecision is how many decimal places TPS values are rounded to
+// when metrics.tps_precision is not set
+const (
+	defaultTPSPrecision = 2
+	maxTPSPrecision     = 10
+)
+
+// tpsPrecisionOrDefault validates a configured TPS precision, falling back to the default
+func tpsPrecisionOrDefault(precision *int) int {
+	if precision == nil {
+		return defaultTPSPrecision
+	}
+	if *precision < -1 || *precision > maxTPSPrecision {
+		logrus.Warnf("Ignoring metrics.tps_precision %d: must be from -1 (full precision) to %d", *precision, maxTPSPrecision)
+		return defaultTPSPrecision
+	}
+	return *precision
+}
+
+// queryTPSPrecision returns the ?precision= override of metrics.tps_precision,
+// answering 400 and returning false when it is malformed
+func (ws *WebhookServer) queryTPSPrecision(c *gin.Context) (int, bool) {
+	raw := c.Query("precision")
+	if raw == "" {
+		return ws.tpsPrecision, true
+	}
+	precision, err := strconv.Atoi(raw)
+	if err != nil || precision < -1 || precision > maxTPSPrecision {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest,
+			fmt.Sprintf("precision must be an integer from -1 (full precision) to %d", maxTPSPrecision))
+		return 0, false
+	}
+	return precision, true
+}
+
+// roundTPS rounds a TPS value to precision decimal places for output; -1
+// keeps full precision. The calculators always keep the unrounded value.
+func roundTPS(tps float64, precision int) float64 {
+	if precision < 0 {
+		return tps
+	}
+	scale := math.Pow(10, float64(precision))
+	return math.Round(tps*scale) / scale
+}
+
+// roundMetricsTPS rounds the TPS entries of a metrics map in place
+func roundMetricsTPS(metrics map[string]interface{}, precision int) {
+	for _, key := range []string{"tps", "active_tps"} {
+		if tps, ok := metrics[key].(float64); ok {
+			metrics[key] = roundTPS(tps, precision)
+		}
+	}
+}
+
+// percentileKey names a percentile metric, e.g. 99.9 becomes "p99_9_ms"
+func percentileKey(p float64) string {
+	return "p" + strings.ReplaceAll(strconv.FormatFloat(p, 'f', -1, 64), ".", "_") + "_ms"
+}
+
+// latencyPercentile returns the p-th percentile (nearest rank) of the
+// sorted latencies in milliseconds
+func latencyPercentile(sorted []time.Duration, p float64) float64 {
+	return float64(nearestRank(sorted, p)) / float64(time.Millisecond)
+}
+
+// nearestRank returns the p-th percentile of sorted samples by the nearest-rank method
+func nearestRank[T any](sorted []T, p float64) T {
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// RecordRenderError counts a response whose body could not be rendered
+func (t *TPSCalculator) RecordRenderError() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.renderErrors++
+}
+
+// RecordTimeoutError counts a request that exceeded its handler deadline
+func (t *TPSCalculator) RecordTimeoutError() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.timeoutErrs++
+}
+
+// RecordDelaySkipped counts a request whose delay exceeded the server's max_effective_delay
+func (t *TPSCalculator) RecordDelaySkipped() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.delaySkipped++
+}
+
+// RecordQueueRejected counts a request turned away because the wait queue was full
+func (t *TPSCalculator) RecordQueueRejected() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.queueRejects++
+}
+
+// RecordQueueWait records how long a request waited for a concurrency slot
+func (t *TPSCalculator) RecordQueueWait(wait time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.queuedCount++
+	t.queueWait += wait
+	if wait > t.queueWaitMax {
+		t.queueWaitMax = wait
+	}
+}
+
+// RecordNotModified counts a conditional request answered with 304
+func (t *TPSCalculator) RecordNotModified() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.notModified++
+}
+
+// ClaimFailFirst decides whether a request falls within the first n since the
+// last reset and, if so, counts it as failed by design. attempt is 1-based.
+// Pausing only stops the fail_first_failures metric; requests still fail.
+func (t *TPSCalculator) ClaimFailFirst(n int) (attempt int64, fail bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.failFirstSeen >= int64(n) {
+		return 0, false
+	}
+
+	t.failFirstSeen++
+	if !t.paused {
+		t.failFirst++
+	}
+	return t.failFirstSeen, true
+}
+
+// PeekFailFirst reports what ClaimFailFirst would return, without claiming the attempt
+func (t *TPSCalculator) PeekFailFirst(n int) (attempt int64, fail bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.failFirstSeen >= int64(n) {
+		return 0, false
+	}
+	return t.failFirstSeen + 1, true
+}
+
+// RecordChunkedResponse counts a response sent with chunked transfer encoding
+func (t *TPSCalculator) RecordChunkedResponse() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.chunkedResponses++
+}
+
+// RecordBodySize records the size of a request body in bytes
+func (t *TPSCalculator) RecordBodySize(size int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.bodyCount++
+	t.bodyBytes += size
+	if size > t.bodyMax {
+		t.bodyMax = size
+	}
+}
+
+// RecordBodyDelay records the delay_per_kb share of a request's delay
+func (t *TPSCalculator) RecordBodyDelay(delay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.bodyDelayCount++
+	t.bodyDelayTotal += delay
+	if delay > t.bodyDelayMax {
+		t.bodyDelayMax = delay
+	}
+}
+
+// RecordStatus counts a sent response by its status class (2xx, 4xx, ...)
+func (t *TPSCalculator) RecordStatus(status int) {
+	class := status / 100
+	if class < 1 || class > 5 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.statusClass[class]++
+	if class == 5 {
+		t.bucketLocked(time.Now().Unix()).ServerErrors++
+	}
+}
+
+// RecordUpstream records the outcome and latency of a forwarded request
+func (t *TPSCalculator) RecordUpstream(latency time.Duration, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	if !ok {
+		t.upstreamErrs++
+		return
+	}
+	t.upstreamOK++
+	t.upstreamTime += latency
+	if latency > t.upstreamMax {
+		t.upstreamMax = latency
+	}
+}
+
+// RecordMethod increments the per-HTTP-method request counter
+func (t *TPSCalculator) RecordMethod(method string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.paused {
+		return
+	}
+
+	t.methodCounts[method]++
+}
+
+// Totals returns the request count and cumulative TPS
+func (t *TPSCalculator) Totals() (int64, float64) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if !t.isActive {
+		return 0, 0
+	}
+	return t.requestCount, t.tpsLocked()
 }
 
-func (ws *WebhookServer) createWebhook(name, path string, config WebhookConfig) *Webhook {
-	ws.mu.Lock()
-	defer ws.mu.Unlock()
+// History returns the archived run summaries, oldest first
+func (t *TPSCalculator) History() []RunSummary {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 
-	// Generate unique ID
-	id := strings.ReplaceAll(uuid.New().String(), "-", "")[:8]
+	history := make([]RunSummary, len(t.history))
+	copy(history, t.history)
+	return history
+}
 
-	// Use custom path if provided, otherwise use /w/{id}
-	finalPath := path
-	if finalPath == "" {
-		finalPath = "/w/" + id
-	} else {
-		// Ensure path starts with /
-		if !strings.HasPrefix(finalPath, "/") {
-			finalPath = "/" + finalPath
+// activeTPSLocked averages throughput over only the seconds (within bucket
+// retention) that saw at least one request, so idle gaps don't dilute it.
+// The caller must hold t.mu.
+func (t *TPSCalculator) activeTPSLocked() float64 {
+	oldest := time.Now().Unix() - bucketRetentionSeconds
+	var requests, activeSeconds int64
+	for _, bucket := range t.buckets {
+		if bucket.Count > 0 && bucket.Second > oldest {
+			requests += bucket.Count
+			activeSeconds++
 		}
 	}
+	if activeSeconds == 0 {
+		return 0
+	}
+	return float64(requests) / float64(activeSeconds)
+}
 
-	webhook := &Webhook{
-		ID:         id,
-		Name:       name,
-		Path:       finalPath,
-		Config:     config,
-		Calculator: NewTPSCalculator(),
-		CreatedAt:  time.Now(),
+// Pause stops recording until Resume, without clearing anything
+func (t *TPSCalculator) Pause() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.paused {
+		t.paused = true
+		t.pausedAt = time.Now()
 	}
+}
+
+// Resume records again; the paused time is left out of the TPS duration
+func (t *TPSCalculator) Resume() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	ws.webhooks[id] = webhook
+	if t.paused {
+		t.paused = false
+		t.pendingPause += time.Since(t.pausedAt)
+	}
+}
 
-	// Register the custom path route
-	ws.registerWebhookRoute(webhook)
+// IsPaused reports whether recording is paused
+func (t *TPSCalculator) IsPaused() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 
-	return webhook
+	return t.paused
 }
 
-func (ws *WebhookServer) registerWebhookRoute(webhook *Webhook) {
-	ws.router.Any(webhook.Path, func(c *gin.Context) {
-		ws.handleWebhookRequest(webhook.ID, c)
-	})
+// durationLocked is the time between the first and last request minus the
+// time spent paused in between; the caller must hold t.mu
+func (t *TPSCalculator) durationLocked() time.Duration {
+	return t.lastTime.Sub(t.startTime) - t.pausedTotal
 }
 
-func (ws *WebhookServer) handleWebhookRequest(webhookID string, c *gin.Context) {
-	webhook, exists := ws.getWebhook(webhookID)
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
-		return
+// tpsLocked computes the cumulative TPS; the caller must hold t.mu
+func (t *TPSCalculator) tpsLocked() float64 {
+	duration := t.durationLocked().Seconds()
+	if duration <= 0 {
+		return 0
 	}
+	return float64(t.requestCount) / duration
+}
 
-	// Record request for metrics
-	webhook.Calculator.RecordRequest()
+// GetMetrics returns a snapshot of the metrics, with a latency entry per percentile
+func (t *TPSCalculator) GetMetrics(percentiles []float64) map[string]interface{} {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 
-	// Update last request time
-	now := time.Now()
-	webhook.LastRequest = &now
+	// Copy method counts so callers never share the internal map
+	methodCounts := make(map[string]int64, len(t.methodCounts))
+	for method, count := range t.methodCounts {
+		methodCounts[method] = count
+	}
 
-	// Read and log request body if logging is enabled
-	var requestBody string
-	var requestHeaders map[string][]string
-	if webhook.Config.EnableLogging {
-		// Read request body
-		bodyBytes, err := io.ReadAll(c.Request.Body)
-		if err == nil {
-			requestBody = string(bodyBytes)
-			// Restore the request body for further processing
-			c.Request.Body = io.NopCloser(strings.NewReader(requestBody))
-		}
-		
-		// Copy request headers
-		requestHeaders = make(map[string][]string)
-		for key, values := range c.Request.Header {
-			requestHeaders[key] = values
+	patternHits := make(map[string]int64, len(t.patternHits))
+	for rule, count := range t.patternHits {
+		patternHits[rule] = count
+	}
+	labelCounts := make(map[string]int64, len(t.labelCounts))
+	for value, count := range t.labelCounts {
+		labelCounts[value] = count
+	}
+	variantCounts := make(map[string]int64, len(t.variantCounts))
+	for name, count := range t.variantCounts {
+		variantCounts[name] = count
+	}
+
+	methodAvgLatency := make(map[string]float64, len(t.methodTimes))
+	for method, stats := range t.methodTimes {
+		methodAvgLatency[method] = float64(stats.total) / float64(stats.count) / float64(time.Millisecond)
+	}
+
+	// Counters that are reported even before the first completed request
+	metrics := map[string]interface{}{
+		"total_requests":           0,
+		"duration_seconds":         0,
+		"tps":                      0,
+		"active_tps":               0,
+		"peak_tps":                 0,
+		"time_to_first_request_ms": nil,
+		"start_time":               nil,
+		"end_time":                 nil,
+		"method_counts":            methodCounts,
+		"method_avg_ms":            methodAvgLatency,
+		"delay_pattern_hits":       patternHits,
+		"label_counts":             labelCounts,
+		"variant_counts":           variantCounts,
+		"min_interval_ms":          nil,
+		"max_interval_ms":          nil,
+		"cancelled_requests":       t.cancelled,
+		"schema_failures":          t.schemaFails,
+		"signature_failures":       t.signatureFails,
+		"missing_header_errors":    t.missingHeaders,
+		"uri_too_long_errors":      t.uriTooLong,
+		"fan_in_requests":          t.fanIn,
+		"repeat_failures":          t.repeatFails,
+		"cold_starts":              t.coldStarts,
+		"circuit_opens":            t.circuitOpens,
+		"circuit_rejections":       t.circuitRejections,
+		"render_errors":            t.renderErrors,
+		"timeout_errors":           t.timeoutErrs,
+		"delays_skipped":           t.delaySkipped,
+		"not_modified":             t.notModified,
+		"chunked_responses":        t.chunkedResponses,
+		"fail_first_failures":      t.failFirst,
+		"avg_body_bytes":           nil,
+		"max_body_bytes":           nil,
+		"body_delay_avg_ms":        nil,
+		"body_delay_max_ms":        nil,
+		"queue_rejections":         t.queueRejects,
+		"queued_requests":          t.queuedCount,
+		"queue_wait_avg_ms":        nil,
+		"queue_wait_max_ms":        nil,
+		"status_1xx":               t.statusClass[1],
+		"status_2xx":               t.statusClass[2],
+		"status_3xx":               t.statusClass[3],
+		"status_4xx":               t.statusClass[4],
+		"status_5xx":               t.statusClass[5],
+		"upstream_requests":        t.upstreamOK,
+		"upstream_errors":          t.upstreamErrs,
+		"upstream_avg_ms":          nil,
+		"upstream_max_ms":          nil,
+		"in_flight":                t.inFlight.Load(),
+		"paused":                   t.paused,
+		"paused_seconds":           t.pausedTotal.Seconds(),
+		"delay_avg_ms":             nil,
+		"delay_p50_ms":             nil,
+		"delay_p95_ms":             nil,
+		"delay_max_ms":             nil,
+		"deadline_exceeded":        t.deadlineExceeded,
+		"unique_clients":           int64(0),
+		"deadline_utilization_p50": nil,
+		"deadline_utilization_p95": nil,
+		"deadline_utilization_p99": nil,
+		"deadline_utilization_max": nil,
+	}
+	for _, p := range percentiles {
+		metrics[percentileKey(p)] = nil
+	}
+
+	if t.bodyCount > 0 {
+		metrics["avg_body_bytes"] = float64(t.bodyBytes) / float64(t.bodyCount)
+		metrics["max_body_bytes"] = t.bodyMax
+	}
+
+	if t.bodyDelayCount > 0 {
+		metrics["body_delay_avg_ms"] = float64(t.bodyDelayTotal) / float64(t.bodyDelayCount) / float64(time.Millisecond)
+		metrics["body_delay_max_ms"] = float64(t.bodyDelayMax) / float64(time.Millisecond)
+	}
+
+	if t.queuedCount > 0 {
+		metrics["queue_wait_avg_ms"] = float64(t.queueWait) / float64(t.queuedCount) / float64(time.Millisecond)
+		metrics["queue_wait_max_ms"] = float64(t.queueWaitMax) / float64(time.Millisecond)
+	}
+
+	if t.upstreamOK > 0 {
+		metrics["upstream_avg_ms"] = float64(t.upstreamTime) / float64(t.upstreamOK) / float64(time.Millisecond)
+		metrics["upstream_max_ms"] = float64(t.upstreamMax) / float64(time.Millisecond)
+	}
+
+	// Latency percentiles over the most recent samples
+	if len(t.latencies) > 0 {
+		sorted := make([]time.Duration, len(t.latencies))
+		copy(sorted, t.latencies)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		for _, p := range percentiles {
+			metrics[percentileKey(p)] = latencyPercentile(sorted, p)
 		}
+	}
 
-		// Log request details
-		logrus.WithFields(logrus.Fields{
-			"webhook_id":      webhookID,
-			"method":          c.Request.Method,
-			"path":            c.Request.URL.Path,
-			"query_params":    c.Request.URL.RawQuery,
-			"ip":              c.ClientIP(),
-			"user_agent":      c.GetHeader("User-Agent"),
-			"webhook":         webhook.Name,
-			"request_headers": requestHeaders,
-			"request_body":    requestBody,
-			"content_length":  c.Request.ContentLength,
-		}).Info("Request received")
+	// Realized artificial delay over the most recent samples
+	if len(t.delays) > 0 {
+		sorted := make([]time.Duration, len(t.delays))
+		copy(sorted, t.delays)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		var total time.Duration
+		for _, delay := range sorted {
+			total += delay
+		}
+		metrics["delay_avg_ms"] = float64(total) / float64(len(sorted)) / float64(time.Millisecond)
+		metrics["delay_p50_ms"] = latencyPercentile(sorted, 50)
+		metrics["delay_p95_ms"] = latencyPercentile(sorted, 95)
+		metrics["delay_max_ms"] = float64(sorted[len(sorted)-1]) / float64(time.Millisecond)
 	}
 
-	// Apply timeout if configured
-	if webhook.Config.Timeout > 0 {
-		time.Sleep(time.Duration(webhook.Config.Timeout) * time.Millisecond)
+	if t.clientSketch != nil {
+		metrics["unique_clients"] = t.clientSketch.estimate()
 	}
 
-	// Set custom headers
-	responseHeaders := make(map[string]string)
-	for key, value := range webhook.Config.Headers {
-		c.Header(key, value)
-		responseHeaders[key] = value
+	// Deadline budget used by the most recent requests, 1.0 being the whole budget
+	if len(t.deadlineUse) > 0 {
+		sorted := make([]float64, len(t.deadlineUse))
+		copy(sorted, t.deadlineUse)
+		sort.Float64s(sorted)
+		metrics["deadline_utilization_p50"] = nearestRank(sorted, 50)
+		metrics["deadline_utilization_p95"] = nearestRank(sorted, 95)
+		metrics["deadline_utilization_p99"] = nearestRank(sorted, 99)
+		metrics["deadline_utilization_max"] = sorted[len(sorted)-1]
 	}
 
-	// Set content type and prepare response
-	c.Header("Content-Type", webhook.Config.ContentType)
-	responseHeaders["Content-Type"] = webhook.Config.ContentType
+	if !t.isActive {
+		return metrics
+	}
 
-	// Send response
-	c.String(webhook.Config.StatusCode, webhook.Config.ResponseBody)
+	metrics["total_requests"] = t.requestCount
+	metrics["duration_seconds"] = t.durationLocked().Seconds()
+	metrics["tps"] = t.tpsLocked()
+	metrics["active_tps"] = t.activeTPSLocked()
+	metrics["peak_tps"] = t.peakLocked()
+	metrics["start_time"] = t.startTime.Format(time.RFC3339)
+	metrics["time_to_first_request_ms"] = float64(t.startTime.Sub(t.armedAt)) / float64(time.Millisecond)
+	metrics["end_time"] = t.lastTime.Format(time.RFC3339)
 
-	// Log response details if logging is enabled
-	if webhook.Config.EnableLogging {
-		logrus.WithFields(logrus.Fields{
-			"webhook_id":       webhookID,
-			"webhook":          webhook.Name,
-			"response_status":  webhook.Config.StatusCode,
-			"response_headers": responseHeaders,
-			"response_body":    webhook.Config.ResponseBody,
-			"processing_time":  time.Since(now).String(),
-		}).Info("Response sent")
+	// Intervals are only meaningful once two requests have been seen
+	if t.hasInterval {
+		metrics["min_interval_ms"] = float64(t.minInterval) / float64(time.Millisecond)
+		metrics["max_interval_ms"] = float64(t.maxInterval) / float64(time.Millisecond)
 	}
+
+	return metrics
 }
 
-func (ws *WebhookServer) getWebhook(id string) (*Webhook, bool) {
-	ws.mu.RLock()
-	defer ws.mu.RUnlock()
+func (t *TPSCalculator) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	webhook, exists := ws.webhooks[id]
-	return webhook, exists
+	// Archive the period that just ended; runs without requests are not kept
+	if t.isActive {
+		t.history = append(t.history, RunSummary{
+			StartTime:     t.startTime,
+			EndTime:       t.lastTime,
+			TotalRequests: t.requestCount,
+			TPS:           t.tpsLocked(),
+			PeakTPS:       t.peakLocked(),
+		})
+		if len(t.history) > runHistorySize {
+			t.history = t.history[len(t.history)-runHistorySize:]
+		}
+	}
+
+	t.requestCount = 0
+	t.startTime = time.Time{}
+	t.armedAt = time.Now()
+	t.lastTime = time.Time{}
+	t.isActive = false
+	t.pendingPause = 0
+	t.pausedTotal = 0
+	t.methodCounts = make(map[string]int64)
+	t.methodTimes = make(map[string]*methodLatency)
+	t.patternHits = make(map[string]int64)
+	t.labelCounts = make(map[string]int64)
+	t.variantCounts = make(map[string]int64)
+	t.minInterval = 0
+	t.maxInterval = 0
+	t.hasInterval = false
+	t.cancelled = 0
+	t.schemaFails = 0
+	t.signatureFails = 0
+	t.missingHeaders = 0
+	t.uriTooLong = 0
+	t.fanIn = 0
+	t.repeatFails = 0
+	t.coldStarts = 0
+	t.circuitOpens = 0
+	t.circuitRejections = 0
+	t.renderErrors = 0
+	t.timeoutErrs = 0
+	t.delaySkipped = 0
+	t.notModified = 0
+	t.chunkedResponses = 0
+	t.failFirst = 0
+	t.failFirstSeen = 0
+	t.bodyCount = 0
+	t.bodyBytes = 0
+	t.bodyMax = 0
+	t.bodyDelayCount = 0
+	t.bodyDelayTotal = 0
+	t.bodyDelayMax = 0
+	t.queueRejects = 0
+	t.queuedCount = 0
+	t.queueWait = 0
+	t.queueWaitMax = 0
+	t.statusClass = [6]int64{}
+	t.upstreamOK = 0
+	t.upstreamErrs = 0
+	t.upstreamTime = 0
+	t.upstreamMax = 0
+	t.latencies = nil
+	t.latencyNext = 0
+	t.delays = nil
+	t.delayNext = 0
+	t.deadlineUse = nil
+	t.deadlineNext = 0
+	t.deadlineExceeded = 0
+	t.clientSketch = nil
+	t.buckets = nil
 }
 
-func (ws *WebhookServer) getAllWebhooks() []*Webhook {
-	ws.mu.RLock()
-	defer ws.mu.RUnlock()
+// apiOperation describes one management endpoint for the OpenAPI document.
+// Paths use Gin syntax; RequestSchema and ResponseSchema name component schemas.
+type apiOperation struct {
+	Method         string
+	Path           string
+	Summary        string
+	RequestSchema  string
+	ResponseSchema string
+}
 
-	webhooks := make([]*Webhook, 0, len(ws.webhooks))
-	for _, webhook := range ws.webhooks {
-		webhooks = append(webhooks, webhook)
-	}
-	return webhooks
+// apiOperations must list every /api route registered in main(); a startup
+// check logs a warning for any route missing here.
+var apiOperations = []apiOperation{
+	{"GET", "/api/webhooks", "List webhooks (paginated with limit and offset)", "", "WebhookPage"},
+	{"POST", "/api/webhooks", "Create a webhook", "CreateWebhookRequest", "Webhook"},
+	{"GET", "/api/webhooks/:id", "Get a webhook", "", "Webhook"},
+	{"PUT", "/api/webhooks/:id", "Update a webhook (merge non-empty fields, or ?replace=true for a full config replace)", "UpdateWebhookRequest", "Webhook"},
+	{"PATCH", "/api/webhooks/:id", "Partially update a webhook", "UpdateWebhookRequest", "Webhook"},
+	{"DELETE", "/api/webhooks/:id", "Delete a webhook", "", "Message"},
+	{"POST", "/api/webhooks/bulk", "Create several webhooks at once (optionally atomic)", "BulkCreateRequest", "Object"},
+	{"PUT", "/api/webhooks/bulk", "Update several webhooks at once", "BulkUpdateRequest", "Object"},
+	{"GET", "/api/webhooks/:id/metrics", "Get webhook metrics (?detailed=true adds recent_seconds)", "", "Metrics"},
+	{"POST", "/api/webhooks/:id/match", "Dry-run a synthetic request and show the response it would get", "Object", "Object"},
+	{"GET", "/api/webhooks/:id/history", "Summaries of previous runs archived by reset", "", "Object"},
+	{"GET", "/api/webhooks/:id/health", "Health (healthy, degraded or idle) from recent activity", "", "Object"},
+	{"GET", "/api/webhooks/:id/errors", "Most recent error events (newest first)", "", "Object"},
+	{"GET", "/api/webhooks/:id/clients", "Top client IPs by request count (?limit=, default 10)", "", "Object"},
+	{"GET", "/api/webhooks/:id/requests.jsonl", "Recent request summaries as JSON Lines (?limit=)", "", "Object"},
+	{"GET", "/api/webhooks/:id/histogram", "Per-second request histogram (query: since, resolution)", "", "Object"},
+	{"GET", "/api/webhooks/:id/metrics/range", "Total, average and peak TPS between two times (query: from, to)", "", "Object"},
+	{"POST", "/api/webhooks/:id/reset", "Reset webhook metrics", "", "Message"},
+	{"POST", "/api/webhooks/:id/pause", "Stop recording webhook metrics; requests are still served", "", "Object"},
+	{"POST", "/api/webhooks/:id/resume", "Record webhook metrics again after a pause", "", "Object"},
+	{"GET", "/api/requests", "Request logs (disabled, see console)", "", "Object"},
+	{"DELETE", "/api/requests", "Clear request logs (disabled)", "", "Message"},
+	{"GET", "/api/config", "Get the default webhook config (legacy)", "", "WebhookConfig"},
+	{"POST", "/api/config", "Replace the default webhook config (legacy)", "WebhookConfig", "Message"},
+	{"GET", "/api/config/export", "Download the running webhooks and server settings as config.yaml", "", ""},
+	{"POST", "/api/config/import", "Create/update webhooks from a config.yaml (YAML or JSON body, ?mode=merge|replace)", "", "Object"},
+	{"POST", "/api/request", "Record a request on the default webhook (legacy)", "", "Object"},
+	{"GET", "/api/metrics", "Get default webhook metrics (legacy), or ?ids=a,b metrics of those webhooks in request order", "", "Metrics"},
+	{"POST", "/api/reset", "Reset default webhook metrics (legacy)", "", "Message"},
+	{"GET", "/api/summary", "Metrics summary for all webhooks (?include_config=true adds redacted configs)", "", "Object"},
+	{"GET", "/api/metrics/all", "Full metrics for every webhook keyed by ID (?tag= filters)", "", "Object"},
+	{"GET", "/api/status", "Server start time, uptime, webhook count, total requests and build info", "", "Object"},
+	{"GET", "/api/debug/stats", "Goroutine count, memory statistics and running background tasks", "", "Object"},
+	{"GET", "/api/server/metrics", "Server-wide metrics across all webhooks", "", "Metrics"},
+	{"GET", "/api/summary/by-tag", "Metrics aggregated per tag", "", "Object"},
+	{"GET", "/api/summary/influx", "All webhook metrics in InfluxDB line protocol (text/plain)", "", ""},
+	{"GET", "/api/routes", "List live routes", "", "Object"},
+	{"GET", "/api/openapi.json", "This OpenAPI document", "", "Object"},
 }
 
-func (ws *WebhookServer) deleteWebhook(id string) bool {
-	ws.mu.Lock()
-	defer ws.mu.Unlock()
+// buildOpenAPISpec builds the OpenAPI 3.0 document for the management API
+func buildOpenAPISpec() map[string]interface{} {
+	paths := make(map[string]interface{})
+	for _, op := range apiOperations {
+		// Convert Gin ":param" segments to OpenAPI "{param}"
+		segments := strings.Split(op.Path, "/")
+		var parameters []interface{}
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, ":") {
+				name := segment[1:]
+				segments[i] = "{" + name + "}"
+				parameters = append(parameters, map[string]interface{}{
+					"name":     name,
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]interface{}{"type": "string"},
+				})
+			}
+		}
+		openAPIPath := strings.Join(segments, "/")
 
-	// Don't allow deleting default webhooks
-	if id == "default" || id == "fast" || id == "slow" {
-		return false
+		operation := map[string]interface{}{
+			"summary": op.Summary,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "Success",
+					"content":     openAPIContent(op.ResponseSchema),
+				},
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content":     openAPIContent("Error"),
+				},
+			},
+		}
+		if parameters != nil {
+			operation["parameters"] = parameters
+		}
+		if op.RequestSchema != "" {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  openAPIContent(op.RequestSchema),
+			}
+		}
+
+		pathItem, exists := paths[openAPIPath].(map[string]interface{})
+		if !exists {
+			pathItem = make(map[string]interface{})
+			paths[openAPIPath] = pathItem
+		}
+		pathItem[strings.ToLower(op.Method)] = operation
 	}
 
-	if _, exists := ws.webhooks[id]; exists {
-		delete(ws.webhooks, id)
-		return true
+	object := map[string]interface{}{"type": "object", "additionalProperties": true}
+	webhookSchema := openAPISchema(reflect.TypeOf(Webhook{}))
+	webhookSchema["properties"].(map[string]interface{})["age_seconds"] = map[string]interface{}{"type": "number"}
+	updateSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":   map[string]interface{}{"type": "string"},
+			"path":   map[string]interface{}{"type": "string"},
+			"tags":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"config": map[string]interface{}{"$ref": "#/components/schemas/WebhookConfig"},
+		},
+	}
+	createProperties := map[string]interface{}{
+		"ttl": map[string]interface{}{"type": "string", "description": "Time to live, e.g. 30m"},
+		"id":  map[string]interface{}{"type": "string", "pattern": "^[A-Za-z0-9_-]{1,64}$", "description": "Stable ID; random when omitted"},
+	}
+	for name, property := range updateSchema["properties"].(map[string]interface{}) {
+		createProperties[name] = property
+	}
+	createSchema := map[string]interface{}{
+		"type":       "object",
+		"required":   []string{"name"},
+		"properties": createProperties,
 	}
-	return false
-}
 
-func (t *TPSCalculator) RecordRequest() {
-	t.mu.Lock()
-	defer t.mu.Unlock()
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "TPS Calculator Webhook Server",
+			"description": "Management API for configurable mock webhooks and their TPS metrics",
+			"version":     "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"WebhookConfig": openAPISchema(reflect.TypeOf(WebhookConfig{})),
+				"Webhook":       webhookSchema,
+				"WebhookPage": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"total":  map[string]interface{}{"type": "integer"},
+						"limit":  map[string]interface{}{"type": "integer"},
+						"offset": map[string]interface{}{"type": "integer"},
+						"items":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/components/schemas/Webhook"}},
+					},
+				},
+				"CreateWebhookRequest": createSchema,
+				"UpdateWebhookRequest": updateSchema,
+				"BulkCreateRequest": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"atomic":   map[string]interface{}{"type": "boolean"},
+						"webhooks": map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/components/schemas/CreateWebhookRequest"}},
+					},
+				},
+				"BulkUpdateRequest": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"updates": map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"$ref": "#/components/schemas/UpdateWebhookRequest"}},
+					},
+				},
+				"Metrics": object,
+				"Object":  object,
+				"Message": map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"message": map[string]interface{}{"type": "string"}},
+				},
+				"Error": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"error": map[string]interface{}{
+							"type":     "object",
+							"required": []string{"code", "message"},
+							"properties": map[string]interface{}{
+								"code":    map[string]interface{}{"type": "string", "enum": []string{codeInvalidRequest, codeInvalidConfig, codeWebhookNotFound, codePathConflict, codeIDConflict, codeWebhookLimit, codeBulkCreateFailed, codeInternalError}},
+								"message": map[string]interface{}{"type": "string"},
+								"details": map[string]interface{}{},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
 
-	now := time.Now()
+// openAPIContent returns a JSON content entry referencing a component schema
+func openAPIContent(schemaName string) map[string]interface{} {
+	if schemaName == "" {
+		schemaName = "Object"
+	}
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": map[string]interface{}{"$ref": "#/components/schemas/" + schemaName},
+		},
+	}
+}
 
-	if !t.isActive {
-		t.startTime = now
-		t.isActive = true
+// openAPISchema derives a schema from a Go type using its json tags, so the
+// documented WebhookConfig and Webhook never drift from the real structs
+func openAPISchema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
 	}
 
-	t.requestCount++
-	t.lastTime = now
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": openAPISchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": openAPISchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = openAPISchema(field.Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	return map[string]interface{}{}
 }
 
-func (t *TPSCalculator) GetMetrics() map[string]interface{} {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
+// checkOpenAPISync warns about /api routes that are missing from apiOperations
+func checkOpenAPISync(routes gin.RoutesInfo) {
+	for _, route := range undocumentedRoutes(routes) {
+		logrus.Warnf("Route %s %s is missing from the OpenAPI spec", route.Method, route.Path)
+	}
+}
 
-	if !t.isActive {
-		return map[string]interface{}{
-			"total_requests":   0,
-			"duration_seconds": 0,
-			"tps":              0,
-			"start_time":       nil,
-			"end_time":         nil,
+// undocumentedRoutes returns the /api routes that have no entry in apiOperations
+func undocumentedRoutes(routes gin.RoutesInfo) gin.RoutesInfo {
+	documented := make(map[string]bool, len(apiOperations))
+	for _, op := range apiOperations {
+		documented[op.Method+" "+op.Path] = true
+	}
+	var missing gin.RoutesInfo
+	for _, route := range routes {
+		if strings.HasPrefix(route.Path, "/api/") && !documented[route.Method+" "+route.Path] {
+			missing = append(missing, route)
 		}
 	}
+	return missing
+}
+
+// Machine-readable error codes used in API error responses
+const (
+	codeInvalidRequest   = "INVALID_REQUEST"    // malformed body or query parameters
+	codeInvalidConfig    = "INVALID_CONFIG"     // webhook config failed validation
+	codeWebhookNotFound  = "WEBHOOK_NOT_FOUND"  // unknown webhook ID (or a protected webhook on delete)
+	codePathConflict     = "PATH_CONFLICT"      // path already in use or reserved
+	codeIDConflict       = "ID_CONFLICT"        // requested webhook ID already exists
+	codeWebhookLimit     = "WEBHOOK_LIMIT"      // server.max_webhooks would be exceeded
+	codeBulkCreateFailed = "BULK_CREATE_FAILED" // atomic bulk create aborted; details lists the failures
+	codeInternalError    = "INTERNAL_ERROR"     // unexpected server-side failure
+)
+
+// codedError attaches an API error code to an error
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// withCode tags err with an API error code for respondError
+func withCode(code string, err error) error {
+	return &codedError{code: code, err: err}
+}
 
-	duration := t.lastTime.Sub(t.startTime).Seconds()
-	var tps float64
-	if duration > 0 {
-		tps = float64(t.requestCount) / duration
+// errorCode returns the code attached to err with withCode, or fallback
+func errorCode(err error, fallback string) string {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
 	}
+	return fallback
+}
 
-	return map[string]interface{}{
-		"total_requests":   t.requestCount,
-		"duration_seconds": duration,
-		"tps":              tps,
-		"start_time":       t.startTime.Format(time.RFC3339),
-		"end_time":         t.lastTime.Format(time.RFC3339),
+// errorStatus maps a webhook creation error to its HTTP status
+func errorStatus(err error) int {
+	if errorCode(err, "") == codeIDConflict {
+		return http.StatusConflict
 	}
+	return http.StatusBadRequest
 }
 
-func (t *TPSCalculator) Reset() {
-	t.mu.Lock()
-	defer t.mu.Unlock()
+// respondError writes the standard API error envelope:
+// {"error": {"code": "WEBHOOK_NOT_FOUND", "message": "Webhook not found"}}
+func respondError(c *gin.Context, status int, code, message string) {
+	respondErrorWithDetails(c, status, code, message, nil)
+}
 
-	t.requestCount = 0
-	t.startTime = time.Time{}
-	t.lastTime = time.Time{}
-	t.isActive = false
+// respondErrorWithDetails is respondError with an optional details payload
+func respondErrorWithDetails(c *gin.Context, status int, code, message string, details interface{}) {
+	body := gin.H{"code": code, "message": message}
+	if details != nil {
+		body["details"] = details
+	}
+	c.JSON(status, gin.H{"error": body})
 }
 
 // Custom panic recovery middleware
@@ -419,10 +7072,7 @@ func panicRecoveryMiddleware() gin.HandlerFunc {
 					"error":  err,
 				}).Error("Panic recovered in HTTP handler")
 				
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": "Internal server error",
-					"message": "An unexpected error occurred",
-				})
+				respondError(c, http.StatusInternalServerError, codeInternalError, "An unexpected error occurred")
 				c.Abort()
 			}
 		}()
@@ -430,82 +7080,139 @@ func panicRecoveryMiddleware() gin.HandlerFunc {
 	}
 }
 
-func main() {
-	// Setup logrus for dual output (console + file)
-	logFile, err := os.OpenFile("webhook.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		logrus.Fatalln("Failed to open log file:", err)
+// registerRoutes registers the /w/:id fallback, the catch-all, the management
+// API, the web interface and the probe endpoints on r. Webhook custom paths
+// are registered separately as webhooks are loaded.
+func (ws *WebhookServer) registerRoutes(r *gin.Engine) error {
+	// Dynamic webhook handler for /w/{id} pattern (fallback for webhooks without custom path).
+	// Each webhook is served and counted on exactly one path: a webhook with a
+	// custom path is redirected there instead of being handled twice over.
+	r.Any("/w/:id", func(c *gin.Context) {
+		webhookID := c.Param("id")
+		if webhook, exists := ws.getWebhook(webhookID); exists && webhook.hasPathParams() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook is only served at its path " + webhook.Path})
+			return
+		}
+		if location, redirect := ws.canonicalLocation(webhookID, c.Request.URL); redirect {
+			c.Redirect(http.StatusTemporaryRedirect, location)
+			return
+		}
+		ws.handleWebhookRequest(webhookID, c)
+	})
+
+	// Catch-all handler for unmatched paths. Gin only calls NoRoute when no other
+	// route matched, so real webhook routes are never shadowed.
+	if ws.config.Server.CatchAll {
+		r.NoRoute(func(c *gin.Context) {
+			ws.handleWebhookRequest(catchAllWebhookID, c)
+		})
 	}
 
-	// Dual output: console AND file
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
-	logrus.SetOutput(multiWriter)
+	// Webhook management endpoints
+	r.GET("/api/webhooks", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)))
+		if err != nil || limit < 1 || limit > maxPageSize {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+			return
+		}
+		offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if err != nil || offset < 0 {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "offset must be a non-negative integer")
+			return
+		}
 
-	// Set log format
-	logrus.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp:   true,
-		TimestampFormat: "2006-01-02 15:04:05",
+		// Map iteration order is random, so sort for stable pages
+		webhooks := ws.getAllWebhooks()
+		sort.Slice(webhooks, func(i, j int) bool {
+			if !webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+				return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+			}
+			return webhooks[i].ID < webhooks[j].ID
+		})
+
+		total := len(webhooks)
+		start := offset
+		if start > total {
+			start = total
+		}
+		end := start + limit
+		if end > total {
+			end = total
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+			"items":  webhooks[start:end],
+		})
 	})
 
-	logrus.Info("🎯 Multi-Webhook Server initializing...")
+	r.POST("/api/webhooks", func(c *gin.Context) {
+		var req createWebhookRequest
 
-	r := gin.Default()
-	
-	// Add custom panic recovery middleware
-	r.Use(panicRecoveryMiddleware())
-	
-	webhookServer, config := NewWebhookServer(r)
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
 
-	// Note: Webhook routes are now registered automatically from YAML config
+		ttl, err := req.parseTTL()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
 
-	// Dynamic webhook handler for /w/{id} pattern (fallback for webhooks without custom path)
-	r.Any("/w/:id", func(c *gin.Context) {
-		webhookID := c.Param("id")
-		webhookServer.handleWebhookRequest(webhookID, c)
-	})
+		// Set defaults for config
+		applyCreateDefaults(&req.Config)
 
-	// Webhook management endpoints
-	r.GET("/api/webhooks", func(c *gin.Context) {
-		webhooks := webhookServer.getAllWebhooks()
-		c.JSON(http.StatusOK, webhooks)
+		webhook, err := ws.createWebhook(req.ID, req.Name, req.Path, req.Tags, req.Config, ttl)
+		if err != nil {
+			respondError(c, errorStatus(err), errorCode(err, codeInvalidConfig), err.Error())
+			return
+		}
+		c.JSON(http.StatusCreated, webhook)
 	})
 
-	r.POST("/api/webhooks", func(c *gin.Context) {
-		var req struct {
-			Name   string        `json:"name" binding:"required"`
-			Path   string        `json:"path"`
-			Config WebhookConfig `json:"config"`
+	r.POST("/api/webhooks/bulk", func(c *gin.Context) {
+		var bulkCreateReq struct {
+			Atomic   bool                   `json:"atomic"`
+			Webhooks []createWebhookRequest `json:"webhooks"`
 		}
 
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := c.ShouldBindJSON(&bulkCreateReq); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
 			return
 		}
 
-		// Set defaults for config
-		if req.Config.StatusCode == 0 {
-			req.Config.StatusCode = 200
+		if len(bulkCreateReq.Webhooks) == 0 {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "No webhooks provided")
+			return
 		}
-		if req.Config.ContentType == "" {
-			req.Config.ContentType = "application/json"
+
+		created, failed := ws.createWebhooks(bulkCreateReq.Webhooks, bulkCreateReq.Atomic)
+
+		if bulkCreateReq.Atomic && len(failed) > 0 {
+			respondErrorWithDetails(c, http.StatusBadRequest, codeBulkCreateFailed, "Bulk create aborted, no webhooks were created", failed)
+			return
 		}
-		if req.Config.ResponseBody == "" {
-			req.Config.ResponseBody = `{"message": "Request received"}`
+
+		response := gin.H{
+			"message": "Bulk create completed",
+			"created": created,
 		}
-		if req.Config.Headers == nil {
-			req.Config.Headers = make(map[string]string)
+
+		if len(failed) > 0 {
+			response["failed"] = failed
 		}
-		// EnableLogging defaults to true if not specified
 
-		webhook := webhookServer.createWebhook(req.Name, req.Path, req.Config)
-		c.JSON(http.StatusCreated, webhook)
+		c.JSON(http.StatusCreated, response)
 	})
 
 	r.GET("/api/webhooks/:id", func(c *gin.Context) {
 		id := c.Param("id")
-		webhook, exists := webhookServer.getWebhook(id)
+		webhook, exists := ws.getWebhook(id)
 		if !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
 			return
 		}
 		c.JSON(http.StatusOK, webhook)
@@ -513,43 +7220,56 @@ func main() {
 
 	r.PUT("/api/webhooks/:id", func(c *gin.Context) {
 		id := c.Param("id")
-		webhook, exists := webhookServer.getWebhook(id)
+		webhook, exists := ws.getWebhook(id)
 		if !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
 			return
 		}
 
 		var updateReq struct {
 			Name   string        `json:"name"`
 			Path   string        `json:"path"`
+			Tags   []string      `json:"tags"`
 			Config WebhookConfig `json:"config"`
 		}
 
 		if err := c.ShouldBindJSON(&updateReq); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
 			return
 		}
 
 		// Validate webhook is not nil
 		if webhook == nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Webhook data is corrupted"})
+			respondError(c, http.StatusInternalServerError, codeInternalError, "Webhook data is corrupted")
 			return
 		}
 
-		webhookServer.mu.Lock()
-		defer webhookServer.mu.Unlock()
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
 		
 		// Double-check webhook still exists after acquiring lock
-		webhook, exists = webhookServer.webhooks[id]
+		webhook, exists = ws.webhooks[id]
 		if !exists || webhook == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found or has been deleted"})
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found or has been deleted")
 			return
 		}
 		
+		// Keep the previous state so a config that fails to compile can be rolled back
+		previous := *webhook
+		previous.Config.Headers = cloneHeaders(webhook.Config.Headers)
+
+		// ?replace=true swaps in the whole config instead of merging non-empty fields
+		replace, _ := strconv.ParseBool(c.Query("replace"))
+
 		// Update name if provided
 		if updateReq.Name != "" {
 			webhook.Name = updateReq.Name
 		}
+
+		// Replace tags if provided
+		if updateReq.Tags != nil {
+			webhook.Tags = updateReq.Tags
+		}
 		
 		// Update path if provided (but don't allow changing default webhook paths)
 		if updateReq.Path != "" && id != "default" && id != "fast" && id != "slow" {
@@ -557,205 +7277,781 @@ func main() {
 			if !strings.HasPrefix(updateReq.Path, "/") {
 				updateReq.Path = "/" + updateReq.Path
 			}
+			if err := validateWebhookPath(updateReq.Path); err != nil {
+				*webhook = previous
+				respondError(c, http.StatusBadRequest, codePathConflict, err.Error())
+				return
+			}
+			if err := ws.checkPathFreeLocked(id, updateReq.Path); err != nil {
+				*webhook = previous
+				respondError(c, errorStatus(err), errorCode(err, codePathConflict), err.Error())
+				return
+			}
+			// The route moves once the rest of the update has compiled
 			webhook.Path = updateReq.Path
-			// Note: Route re-registration is not supported in Gin after server starts
-			// Path changes will take effect on next server restart
-		}
-		
-		// Update config - merge with existing config
-		if updateReq.Config.StatusCode != 0 {
-			webhook.Config.StatusCode = updateReq.Config.StatusCode
 		}
-		if updateReq.Config.ContentType != "" {
-			webhook.Config.ContentType = updateReq.Config.ContentType
-		}
-		if updateReq.Config.ResponseBody != "" {
-			webhook.Config.ResponseBody = updateReq.Config.ResponseBody
+
+		if replace {
+			// Full replace: omitted fields fall back to the defaults of a new
+			// webhook, and empty maps in the request are honored
+			webhook.Config = updateReq.Config
+			applyCreateDefaults(&webhook.Config)
+			webhook.Tags = updateReq.Tags
+		} else {
+			// Update config - merge with existing config
+			mergeWebhookConfig(&webhook.Config, updateReq.Config)
 		}
-		if updateReq.Config.Timeout >= 0 {
-			webhook.Config.Timeout = updateReq.Config.Timeout
+
+		err := ws.checkFanOutLocked(webhook)
+		if err == nil {
+			err = webhook.compileConfig(ws.dirs)
 		}
-		if updateReq.Config.Headers != nil {
-			if webhook.Config.Headers == nil {
-				webhook.Config.Headers = make(map[string]string)
-			}
-			for key, value := range updateReq.Config.Headers {
-				webhook.Config.Headers[key] = value
-			}
+		if err != nil {
+			*webhook = previous
+			respondError(c, http.StatusBadRequest, codeInvalidConfig, err.Error())
+			return
 		}
-		// Update logging setting
-		webhook.Config.EnableLogging = updateReq.Config.EnableLogging
+		ws.rerouteWebhookLocked(webhook, previous.Path)
 
 		c.JSON(http.StatusOK, webhook)
 	})
 
 	r.PATCH("/api/webhooks/:id", func(c *gin.Context) {
 		id := c.Param("id")
-		webhook, exists := webhookServer.getWebhook(id)
+		webhook, exists := ws.getWebhook(id)
 		if !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
 			return
 		}
 
 		var patchReq struct {
-			Name   *string `json:"name"`
-			Path   *string `json:"path"`
+			Name   *string   `json:"name"`
+			Path   *string   `json:"path"`
+			Tags   *[]string `json:"tags"`
 			Config *struct {
-				StatusCode    *int               `json:"status_code"`
-				ContentType   *string            `json:"content_type"`
-				ResponseBody  *string            `json:"response_body"`
-				Timeout       *int               `json:"timeout"`
-				Headers       map[string]string  `json:"headers"`
-				EnableLogging *bool              `json:"enable_logging"`
+				StatusCode               *int                   `json:"status_code"`
+				ContentType              *string                `json:"content_type"`
+				ResponseBody             *string                `json:"response_body"`
+				Timeout                  *int                   `json:"timeout"`
+				Headers                  map[string]string      `json:"headers"`
+				EnableLogging            *bool                  `json:"enable_logging"`
+				WriteChunkSize           *int                   `json:"write_chunk_size"`
+				WriteDelayPerChunk       *int                   `json:"write_delay_per_chunk"`
+				RequestSchema            *string                `json:"request_schema"`
+				RequestSchemaFile        *string                `json:"request_schema_file"`
+				LogSampleRate            *float64               `json:"log_sample_rate"`
+				DelayDistribution        *DelayDistribution     `json:"delay_distribution"`
+				LingerMs                 *int                   `json:"linger_ms"`
+				CaptureBodiesTo          *string                `json:"capture_bodies_to"`
+				CaptureMaxSizeMB         *int                   `json:"capture_max_size_mb"`
+				ResponseBodyFile         *string                `json:"response_body_file"`
+				ResponseTemplate         *bool                  `json:"response_template"`
+				PrettyJSON               *bool                  `json:"pretty_json"`
+				HandlerTimeout           *int                   `json:"handler_timeout"`
+				ForwardTo                *string                `json:"forward_to"`
+				MethodTimeouts           *map[string]int        `json:"method_timeouts"`
+				AllowClientDelay         *bool                  `json:"allow_client_delay"`
+				MaxClientDelayMs         *int                   `json:"max_client_delay_ms"`
+				MaxConcurrency           *int                   `json:"max_concurrency"`
+				ETag                     *bool                  `json:"etag"`
+				CacheControl             *string                `json:"cache_control"`
+				MaxQueue                 *int                   `json:"max_queue"`
+				ForwardTimeout           *int                   `json:"forward_timeout"`
+				LogHeaderAllowlist       *[]string              `json:"log_header_allowlist"`
+				LogHeaderDenylist        *[]string              `json:"log_header_denylist"`
+				LogHeaderRedact          *[]string              `json:"log_header_redact"`
+				LogHeaderMaxBytes        *int                   `json:"log_header_max_bytes"`
+				SignatureVerification    *SignatureVerification `json:"signature_verification"`
+				ConcurrencyLatencyFactor *float64               `json:"concurrency_latency_factor"`
+				FailOnRepeat             *FailOnRepeatConfig    `json:"fail_on_repeat"`
+				DisableClientTracking    *bool                  `json:"disable_client_tracking"`
+				DelayPattern             *[]string              `json:"delay_pattern"`
+				DelayPerKB               *int                   `json:"delay_per_kb"`
+				MaxBodyDelayMs           *int                   `json:"max_body_delay_ms"`
+				ColdStart                *ColdStartConfig       `json:"cold_start"`
+				Sequence                 *[]ResponseStep        `json:"sequence"`
+				RandomSeed               *int64                 `json:"random_seed"`
+				RequiredHeaders          *[]string              `json:"required_headers"`
+				RequiredHeadersStatus    *int                   `json:"required_headers_status"`
+				ServerTiming             *bool                  `json:"server_timing"`
+				FanOutTo                 *[]string              `json:"fan_out_to"`
+				MinLatencyMs             *int                   `json:"min_latency_ms"`
+				ResponseSigning          *SignatureVerification `json:"response_signing"`
+				LogBodyJSONPaths         *[]string              `json:"log_body_json_paths"`
+				DeadlineBudgetMs         *int                   `json:"deadline_budget_ms"`
+				QueryResponseParam       *string                `json:"query_response_param"`
+				QueryResponseMap         *map[string]string     `json:"query_response_map"`
+				QueryResponseNotFound    *bool                  `json:"query_response_not_found"`
+				ResponseBodyList         *[]ResponseBodyEntry   `json:"response_body_list"`
+				ForceChunked             *bool                  `json:"force_chunked"`
+				StartupDelayMs           *int                   `json:"startup_delay_ms"`
+				MetricsLabelJSONPath     *string                `json:"metrics_label_json_path"`
+				MetricsLabelMaxValues    *int                   `json:"metrics_label_max_values"`
+				FailFirstN               *int                   `json:"fail_first_n"`
+				TraceRequests            *bool                  `json:"trace_requests"`
+				CircuitBreaker           *CircuitBreakerConfig  `json:"circuit_breaker"`
+				MaxURILength             *int                   `json:"max_uri_length"`
+				ABSplit                  *ABSplitConfig         `json:"ab_split"`
+
+				// An explicit null turns backoff off
+				Backoff nullable[BackoffConfig] `json:"backoff"`
 			} `json:"config"`
 		}
 
 		if err := c.ShouldBindJSON(&patchReq); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
 			return
 		}
 
 		// Validate webhook is not nil
 		if webhook == nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Webhook data is corrupted"})
+			respondError(c, http.StatusInternalServerError, codeInternalError, "Webhook data is corrupted")
 			return
 		}
 
-		webhookServer.mu.Lock()
-		defer webhookServer.mu.Unlock()
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
 		
 		// Double-check webhook still exists after acquiring lock
-		webhook, exists = webhookServer.webhooks[id]
+		webhook, exists = ws.webhooks[id]
 		if !exists || webhook == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found or has been deleted"})
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found or has been deleted")
+			return
+		}
+		
+		// Keep the previous state so a config that fails to compile can be rolled back
+		previous := *webhook
+		previous.Config.Headers = cloneHeaders(webhook.Config.Headers)
+
+		// Update name if provided
+		if patchReq.Name != nil {
+			webhook.Name = *patchReq.Name
+		}
+
+		// Replace tags if provided
+		if patchReq.Tags != nil {
+			webhook.Tags = *patchReq.Tags
+		}
+		
+		// Update path if provided (but don't allow changing default webhook paths)
+		if patchReq.Path != nil && id != "default" && id != "fast" && id != "slow" {
+			newPath := *patchReq.Path
+			// Ensure path starts with /
+			if !strings.HasPrefix(newPath, "/") {
+				newPath = "/" + newPath
+			}
+			if err := validateWebhookPath(newPath); err != nil {
+				*webhook = previous
+				respondError(c, http.StatusBadRequest, codePathConflict, err.Error())
+				return
+			}
+			if err := ws.checkPathFreeLocked(id, newPath); err != nil {
+				*webhook = previous
+				respondError(c, errorStatus(err), errorCode(err, codePathConflict), err.Error())
+				return
+			}
+			// The route moves once the rest of the update has compiled
+			webhook.Path = newPath
+		}
+		
+		// Update config fields individually if provided
+		if patchReq.Config != nil {
+			if patchReq.Config.StatusCode != nil {
+				webhook.Config.StatusCode = *patchReq.Config.StatusCode
+			}
+			if patchReq.Config.ContentType != nil {
+				webhook.Config.ContentType = *patchReq.Config.ContentType
+			}
+			if patchReq.Config.ResponseBody != nil {
+				webhook.Config.ResponseBody = *patchReq.Config.ResponseBody
+			}
+			if patchReq.Config.Timeout != nil {
+				webhook.Config.Timeout = *patchReq.Config.Timeout
+			}
+			if patchReq.Config.Headers != nil {
+				webhook.Config.Headers = cloneHeaders(webhook.Config.Headers)
+				if webhook.Config.Headers == nil {
+					webhook.Config.Headers = make(map[string]string)
+				}
+				for key, value := range patchReq.Config.Headers {
+					webhook.Config.Headers[key] = value
+				}
+			}
+			if patchReq.Config.EnableLogging != nil {
+				webhook.Config.EnableLogging = *patchReq.Config.EnableLogging
+			}
+			if patchReq.Config.WriteChunkSize != nil {
+				webhook.Config.WriteChunkSize = *patchReq.Config.WriteChunkSize
+			}
+			if patchReq.Config.WriteDelayPerChunk != nil {
+				webhook.Config.WriteDelayPerChunk = *patchReq.Config.WriteDelayPerChunk
+			}
+			if patchReq.Config.RequestSchema != nil {
+				webhook.Config.RequestSchema = *patchReq.Config.RequestSchema
+			}
+			if patchReq.Config.RequestSchemaFile != nil {
+				webhook.Config.RequestSchemaFile = *patchReq.Config.RequestSchemaFile
+			}
+			if patchReq.Config.LogSampleRate != nil {
+				webhook.Config.LogSampleRate = *patchReq.Config.LogSampleRate
+			}
+			if patchReq.Config.Backoff.Set {
+				webhook.Config.Backoff = patchReq.Config.Backoff.Value
+			}
+			if patchReq.Config.DelayDistribution != nil {
+				webhook.Config.DelayDistribution = patchReq.Config.DelayDistribution
+			}
+			if patchReq.Config.LingerMs != nil {
+				webhook.Config.LingerMs = *patchReq.Config.LingerMs
+			}
+			if patchReq.Config.CaptureBodiesTo != nil {
+				webhook.Config.CaptureBodiesTo = *patchReq.Config.CaptureBodiesTo
+			}
+			if patchReq.Config.CaptureMaxSizeMB != nil {
+				webhook.Config.CaptureMaxSizeMB = *patchReq.Config.CaptureMaxSizeMB
+			}
+			if patchReq.Config.ResponseBodyFile != nil {
+				webhook.Config.ResponseBodyFile = *patchReq.Config.ResponseBodyFile
+			}
+			if patchReq.Config.ResponseTemplate != nil {
+				webhook.Config.ResponseTemplate = *patchReq.Config.ResponseTemplate
+			}
+			if patchReq.Config.PrettyJSON != nil {
+				webhook.Config.PrettyJSON = *patchReq.Config.PrettyJSON
+			}
+			if patchReq.Config.HandlerTimeout != nil {
+				webhook.Config.HandlerTimeout = *patchReq.Config.HandlerTimeout
+			}
+			if patchReq.Config.ETag != nil {
+				webhook.Config.ETag = *patchReq.Config.ETag
+			}
+			if patchReq.Config.CacheControl != nil {
+				webhook.Config.CacheControl = *patchReq.Config.CacheControl
+			}
+			if patchReq.Config.MaxConcurrency != nil {
+				webhook.Config.MaxConcurrency = *patchReq.Config.MaxConcurrency
+			}
+			if patchReq.Config.MaxQueue != nil {
+				webhook.Config.MaxQueue = *patchReq.Config.MaxQueue
+			}
+			if patchReq.Config.AllowClientDelay != nil {
+				webhook.Config.AllowClientDelay = *patchReq.Config.AllowClientDelay
+			}
+			if patchReq.Config.MaxClientDelayMs != nil {
+				webhook.Config.MaxClientDelayMs = *patchReq.Config.MaxClientDelayMs
+			}
+			if patchReq.Config.MethodTimeouts != nil {
+				webhook.Config.MethodTimeouts = *patchReq.Config.MethodTimeouts
+			}
+			if patchReq.Config.ForwardTo != nil {
+				webhook.Config.ForwardTo = *patchReq.Config.ForwardTo
+			}
+			if patchReq.Config.ForwardTimeout != nil {
+				webhook.Config.ForwardTimeout = *patchReq.Config.ForwardTimeout
+			}
+			if patchReq.Config.LogHeaderAllowlist != nil {
+				webhook.Config.LogHeaderAllowlist = *patchReq.Config.LogHeaderAllowlist
+			}
+			if patchReq.Config.LogHeaderDenylist != nil {
+				webhook.Config.LogHeaderDenylist = *patchReq.Config.LogHeaderDenylist
+			}
+			if patchReq.Config.LogHeaderRedact != nil {
+				webhook.Config.LogHeaderRedact = *patchReq.Config.LogHeaderRedact
+			}
+			if patchReq.Config.LogHeaderMaxBytes != nil {
+				webhook.Config.LogHeaderMaxBytes = *patchReq.Config.LogHeaderMaxBytes
+			}
+			if patchReq.Config.SignatureVerification != nil {
+				webhook.Config.SignatureVerification = patchReq.Config.SignatureVerification
+			}
+			if patchReq.Config.ConcurrencyLatencyFactor != nil {
+				webhook.Config.ConcurrencyLatencyFactor = *patchReq.Config.ConcurrencyLatencyFactor
+			}
+			if patchReq.Config.FailOnRepeat != nil {
+				webhook.Config.FailOnRepeat = patchReq.Config.FailOnRepeat
+			}
+			if patchReq.Config.DisableClientTracking != nil {
+				webhook.Config.DisableClientTracking = *patchReq.Config.DisableClientTracking
+			}
+			if patchReq.Config.DelayPattern != nil {
+				webhook.Config.DelayPattern = *patchReq.Config.DelayPattern
+			}
+			if patchReq.Config.DelayPerKB != nil {
+				webhook.Config.DelayPerKB = *patchReq.Config.DelayPerKB
+			}
+			if patchReq.Config.MaxBodyDelayMs != nil {
+				webhook.Config.MaxBodyDelayMs = *patchReq.Config.MaxBodyDelayMs
+			}
+			if patchReq.Config.ColdStart != nil {
+				webhook.Config.ColdStart = patchReq.Config.ColdStart
+			}
+			if patchReq.Config.Sequence != nil {
+				webhook.Config.Sequence = *patchReq.Config.Sequence
+			}
+			if patchReq.Config.RandomSeed != nil {
+				webhook.Config.RandomSeed = *patchReq.Config.RandomSeed
+			}
+			if patchReq.Config.RequiredHeaders != nil {
+				webhook.Config.RequiredHeaders = *patchReq.Config.RequiredHeaders
+			}
+			if patchReq.Config.RequiredHeadersStatus != nil {
+				webhook.Config.RequiredHeadersStatus = *patchReq.Config.RequiredHeadersStatus
+			}
+			if patchReq.Config.ServerTiming != nil {
+				webhook.Config.ServerTiming = *patchReq.Config.ServerTiming
+			}
+			if patchReq.Config.FanOutTo != nil {
+				webhook.Config.FanOutTo = *patchReq.Config.FanOutTo
+			}
+			if patchReq.Config.MinLatencyMs != nil {
+				webhook.Config.MinLatencyMs = *patchReq.Config.MinLatencyMs
+			}
+			if patchReq.Config.ResponseSigning != nil {
+				webhook.Config.ResponseSigning = patchReq.Config.ResponseSigning
+			}
+			if patchReq.Config.LogBodyJSONPaths != nil {
+				webhook.Config.LogBodyJSONPaths = *patchReq.Config.LogBodyJSONPaths
+			}
+			if patchReq.Config.DeadlineBudgetMs != nil {
+				webhook.Config.DeadlineBudgetMs = *patchReq.Config.DeadlineBudgetMs
+			}
+			if patchReq.Config.QueryResponseParam != nil {
+				webhook.Config.QueryResponseParam = *patchReq.Config.QueryResponseParam
+			}
+			if patchReq.Config.QueryResponseMap != nil {
+				webhook.Config.QueryResponseMap = *patchReq.Config.QueryResponseMap
+			}
+			if patchReq.Config.QueryResponseNotFound != nil {
+				webhook.Config.QueryResponseNotFound = *patchReq.Config.QueryResponseNotFound
+			}
+			if patchReq.Config.ResponseBodyList != nil {
+				webhook.Config.ResponseBodyList = *patchReq.Config.ResponseBodyList
+			}
+			if patchReq.Config.ForceChunked != nil {
+				webhook.Config.ForceChunked = *patchReq.Config.ForceChunked
+			}
+			if patchReq.Config.StartupDelayMs != nil {
+				webhook.Config.StartupDelayMs = *patchReq.Config.StartupDelayMs
+			}
+			if patchReq.Config.MetricsLabelJSONPath != nil {
+				webhook.Config.MetricsLabelJSONPath = *patchReq.Config.MetricsLabelJSONPath
+			}
+			if patchReq.Config.MetricsLabelMaxValues != nil {
+				webhook.Config.MetricsLabelMaxValues = *patchReq.Config.MetricsLabelMaxValues
+			}
+			if patchReq.Config.FailFirstN != nil {
+				webhook.Config.FailFirstN = *patchReq.Config.FailFirstN
+			}
+			if patchReq.Config.TraceRequests != nil {
+				webhook.Config.TraceRequests = *patchReq.Config.TraceRequests
+			}
+			if patchReq.Config.CircuitBreaker != nil {
+				webhook.Config.CircuitBreaker = patchReq.Config.CircuitBreaker
+			}
+			if patchReq.Config.MaxURILength != nil {
+				webhook.Config.MaxURILength = *patchReq.Config.MaxURILength
+			}
+			if patchReq.Config.ABSplit != nil {
+				webhook.Config.ABSplit = patchReq.Config.ABSplit
+			}
+		}
+
+		err := ws.checkFanOutLocked(webhook)
+		if err == nil {
+			err = webhook.compileConfig(ws.dirs)
+		}
+		if err != nil {
+			*webhook = previous
+			respondError(c, http.StatusBadRequest, codeInvalidConfig, err.Error())
+			return
+		}
+		ws.rerouteWebhookLocked(webhook, previous.Path)
+
+		c.JSON(http.StatusOK, webhook)
+	})
+
+	r.PUT("/api/webhooks/bulk", func(c *gin.Context) {
+		var bulkUpdateReq struct {
+			Updates map[string]struct {
+				Name   string        `json:"name"`
+				Config WebhookConfig `json:"config"`
+			} `json:"updates"`
+		}
+
+		if err := c.ShouldBindJSON(&bulkUpdateReq); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+
+		if bulkUpdateReq.Updates == nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "No updates provided")
+			return
+		}
+
+		updatedWebhooks := make(map[string]*Webhook)
+		failedUpdates := make(map[string]string)
+		
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		
+		for webhookID, updateData := range bulkUpdateReq.Updates {
+			webhook, exists := ws.webhooks[webhookID]
+			if !exists || webhook == nil {
+				failedUpdates[webhookID] = "Webhook not found"
+				continue
+			}
+			
+			if updateData.Config.StatusCode != 0 {
+				previousConfig := webhook.Config
+				webhook.Config = updateData.Config
+				err := ws.checkFanOutLocked(webhook)
+				if err == nil {
+					err = webhook.compileConfig(ws.dirs)
+				}
+				if err != nil {
+					webhook.Config = previousConfig
+					failedUpdates[webhookID] = err.Error()
+					continue
+				}
+			}
+			if updateData.Name != "" {
+				webhook.Name = updateData.Name
+			}
+			updatedWebhooks[webhookID] = webhook
+		}
+
+		response := gin.H{
+			"message": "Bulk update completed",
+			"updated": updatedWebhooks,
+		}
+		
+		if len(failedUpdates) > 0 {
+			response["failed"] = failedUpdates
+		}
+
+		c.JSON(http.StatusOK, response)
+	})
+
+	r.DELETE("/api/webhooks/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		if ws.deleteWebhook(id) {
+			c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+		} else {
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found or cannot delete default webhook")
+		}
+	})
+
+	// Metrics endpoints
+	r.GET("/api/webhooks/:id/metrics", func(c *gin.Context) {
+		id := c.Param("id")
+		webhook, exists := ws.getWebhook(id)
+		if !exists {
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
+			return
+		}
+		precision, ok := ws.queryTPSPrecision(c)
+		if !ok {
+			return
+		}
+		metrics := webhookMetrics(webhook, ws.percentiles)
+		roundMetricsTPS(metrics, precision)
+		if detailed, _ := strconv.ParseBool(c.Query("detailed")); detailed {
+			metrics["recent_seconds"] = webhook.Calculator.RecentSeconds(recentSecondsWindow)
+		}
+		if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
+			var text bytes.Buffer
+			writeMetricsText(&text, metrics)
+			c.String(http.StatusOK, text.String())
+			return
+		}
+		c.JSON(http.StatusOK, metrics)
+	})
+
+	// Dry run: how would the webhook answer this request? No metrics or side effects.
+	r.POST("/api/webhooks/:id/match", func(c *gin.Context) {
+		id := c.Param("id")
+		webhook, exists := ws.getWebhook(id)
+		if !exists {
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
+			return
+		}
+
+		var req matchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+
+		if req.ClientIP == "" {
+			req.ClientIP = c.ClientIP()
+		}
+		result, err := matchWebhookRequest(webhook, req)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+
+	r.GET("/api/webhooks/:id/history", func(c *gin.Context) {
+		id := c.Param("id")
+		webhook, exists := ws.getWebhook(id)
+		if !exists {
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
+			return
+		}
+		precision, ok := ws.queryTPSPrecision(c)
+		if !ok {
+			return
+		}
+		runs := webhook.Calculator.History()
+		for i := range runs {
+			runs[i].TPS = roundTPS(runs[i].TPS, precision)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"webhook_id": webhook.ID,
+			"runs":       runs,
+		})
+	})
+
+	// Per-webhook health derived from recent activity
+	r.GET("/api/webhooks/:id/health", func(c *gin.Context) {
+		webhook, exists := ws.getWebhook(c.Param("id"))
+		if !exists {
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
+			return
+		}
+		c.JSON(http.StatusOK, ws.webhookHealth(webhook))
+	})
+
+	// Most recent error events, newest first
+	r.GET("/api/webhooks/:id/errors", func(c *gin.Context) {
+		webhook, exists := ws.getWebhook(c.Param("id"))
+		if !exists {
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"webhook_id": webhook.ID,
+			"errors":     ws.recentErrors(webhook.ID),
+		})
+	})
+
+	// Busiest client IPs by request count
+	r.GET("/api/webhooks/:id/clients", func(c *gin.Context) {
+		id := c.Param("id")
+		webhook, exists := ws.getWebhook(id)
+		if !exists {
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil || limit < 1 || limit > maxTrackedClients {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("limit must be between 1 and %d", maxTrackedClients))
+			return
+		}
+		if webhook.Config.DisableClientTracking {
+			c.JSON(http.StatusOK, gin.H{
+				"webhook_id":  webhook.ID,
+				"tracking":    false,
+				"tracked_ips": 0,
+				"clients":     []ClientStats{},
+			})
+			return
+		}
+		clients, tracked := ws.clientTracker(id).top(limit)
+		c.JSON(http.StatusOK, gin.H{
+			"webhook_id":  webhook.ID,
+			"tracking":    true,
+			"tracked_ips": tracked,
+			"clients":     clients,
+		})
+	})
+
+	// Recent request summaries as JSON Lines, streamed one object per line
+	r.GET("/api/webhooks/:id/requests.jsonl", func(c *gin.Context) {
+		id := c.Param("id")
+		if _, exists := ws.getWebhook(id); !exists {
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
 			return
 		}
-		
-		// Update name if provided
-		if patchReq.Name != nil {
-			webhook.Name = *patchReq.Name
-		}
-		
-		// Update path if provided (but don't allow changing default webhook paths)
-		if patchReq.Path != nil && id != "default" && id != "fast" && id != "slow" {
-			newPath := *patchReq.Path
-			// Ensure path starts with /
-			if !strings.HasPrefix(newPath, "/") {
-				newPath = "/" + newPath
-			}
-			webhook.Path = newPath
-			// Note: Route re-registration is not supported in Gin after server starts
-			// Path changes will take effect on next server restart
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+		if err != nil || limit < 0 {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "limit must be a non-negative integer")
+			return
 		}
-		
-		// Update config fields individually if provided
-		if patchReq.Config != nil {
-			if patchReq.Config.StatusCode != nil {
-				webhook.Config.StatusCode = *patchReq.Config.StatusCode
-			}
-			if patchReq.Config.ContentType != nil {
-				webhook.Config.ContentType = *patchReq.Config.ContentType
-			}
-			if patchReq.Config.ResponseBody != nil {
-				webhook.Config.ResponseBody = *patchReq.Config.ResponseBody
+
+		entries := ws.requestBuffer(id).snapshot(limit)
+		c.Header("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(c.Writer)
+		next := 0
+		c.Stream(func(w io.Writer) bool {
+			if next >= len(entries) {
+				return false
 			}
-			if patchReq.Config.Timeout != nil {
-				webhook.Config.Timeout = *patchReq.Config.Timeout
+			if err := encoder.Encode(entries[next]); err != nil {
+				return false
 			}
-			if patchReq.Config.Headers != nil {
-				if webhook.Config.Headers == nil {
-					webhook.Config.Headers = make(map[string]string)
+			next++
+			return next < len(entries)
+		})
+	})
+
+	// Per-second request histogram, optionally limited with ?since= and downsampled with ?resolution=
+	r.GET("/api/webhooks/:id/histogram", func(c *gin.Context) {
+		id := c.Param("id")
+		webhook, exists := ws.getWebhook(id)
+		if !exists {
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
+			return
+		}
+
+		to := time.Now()
+		from := to.Add(-(bucketRetentionSeconds - 1) * time.Second)
+		// By default cover everything since the first request (within retention)
+		if startTime := webhook.Calculator.StartTime(); startTime.IsZero() {
+			from = to
+		} else if startTime.After(from) {
+			from = startTime
+		}
+
+		// since is either a number of seconds back from now or an RFC3339 timestamp,
+		// clamped to the retention window; future times are rejected
+		if since := c.Query("since"); since != "" {
+			oldest := to.Add(-(bucketRetentionSeconds - 1) * time.Second)
+			if seconds, err := strconv.ParseInt(since, 10, 64); err == nil && seconds >= 0 {
+				from = oldest
+				if seconds < bucketRetentionSeconds {
+					from = to.Add(-time.Duration(seconds) * time.Second)
 				}
-				for key, value := range patchReq.Config.Headers {
-					webhook.Config.Headers[key] = value
+			} else if timestamp, err := time.Parse(time.RFC3339, since); err == nil {
+				if timestamp.After(to) {
+					respondError(c, http.StatusBadRequest, codeInvalidRequest, "since must not be in the future")
+					return
 				}
-			}
-			if patchReq.Config.EnableLogging != nil {
-				webhook.Config.EnableLogging = *patchReq.Config.EnableLogging
+				from = timestamp
+				if from.Before(oldest) {
+					from = oldest
+				}
+			} else {
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, "since must be a number of seconds or an RFC3339 timestamp")
+				return
 			}
 		}
 
-		c.JSON(http.StatusOK, webhook)
-	})
+		resolution := int64(1)
+		if resolutionParam := c.Query("resolution"); resolutionParam != "" {
+			duration, err := time.ParseDuration(resolutionParam)
+			if err != nil || duration < time.Second || duration%time.Second != 0 {
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, "resolution must be a whole number of seconds, e.g. 5s")
+				return
+			}
+			resolution = int64(duration / time.Second)
+		}
 
-	r.PUT("/api/webhooks/bulk", func(c *gin.Context) {
-		var bulkUpdateReq struct {
-			Updates map[string]struct {
-				Name   string        `json:"name"`
-				Config WebhookConfig `json:"config"`
-			} `json:"updates"`
+		buckets := webhook.Calculator.Histogram(from, to, resolution)
+		effectiveFrom := to.UTC().Format(time.RFC3339)
+		if len(buckets) > 0 {
+			effectiveFrom = buckets[0].Time
 		}
 
-		if err := c.ShouldBindJSON(&bulkUpdateReq); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusOK, gin.H{
+			"webhook_id":         id,
+			"resolution_seconds": resolution,
+			"from":               effectiveFrom,
+			"to":                 to.UTC().Format(time.RFC3339),
+			"retention_seconds":  bucketRetentionSeconds,
+			"buckets":            buckets,
+		})
+	})
+
+	// Total, average and peak TPS over an RFC3339 time range within the bucket retention
+	r.GET("/api/webhooks/:id/metrics/range", func(c *gin.Context) {
+		id := c.Param("id")
+		webhook, exists := ws.getWebhook(id)
+		if !exists {
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
 			return
 		}
 
-		if bulkUpdateReq.Updates == nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No updates provided"})
+		now := time.Now().Truncate(time.Second)
+		from, err := time.Parse(time.RFC3339, c.Query("from"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "from must be an RFC3339 timestamp")
 			return
 		}
-
-		updatedWebhooks := make(map[string]*Webhook)
-		failedUpdates := make(map[string]string)
-		
-		webhookServer.mu.Lock()
-		defer webhookServer.mu.Unlock()
-		
-		for webhookID, updateData := range bulkUpdateReq.Updates {
-			webhook, exists := webhookServer.webhooks[webhookID]
-			if !exists || webhook == nil {
-				failedUpdates[webhookID] = "Webhook not found"
-				continue
-			}
-			
-			if updateData.Name != "" {
-				webhook.Name = updateData.Name
+		to := now
+		if toParam := c.Query("to"); toParam != "" {
+			if to, err = time.Parse(time.RFC3339, toParam); err != nil {
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, "to must be an RFC3339 timestamp")
+				return
 			}
-			if updateData.Config.StatusCode != 0 {
-				webhook.Config = updateData.Config
-			}
-			updatedWebhooks[webhookID] = webhook
 		}
-
-		response := gin.H{
-			"message": "Bulk update completed",
-			"updated": updatedWebhooks,
+		if to.After(now) {
+			to = now
 		}
-		
-		if len(failedUpdates) > 0 {
-			response["failed"] = failedUpdates
+		if from.After(to) {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "from must not be after to")
+			return
+		}
+		oldest := now.Add(-(bucketRetentionSeconds - 1) * time.Second)
+		if from.Before(oldest) {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest,
+				fmt.Sprintf("from is before the %ds retention window; the oldest available second is %s",
+					bucketRetentionSeconds, oldest.UTC().Format(time.RFC3339)))
+			return
 		}
 
-		c.JSON(http.StatusOK, response)
+		precision, ok := ws.queryTPSPrecision(c)
+		if !ok {
+			return
+		}
+		total, peak, peakAt := webhook.Calculator.RangeStats(from, to)
+		seconds := to.Unix() - from.Unix() + 1
+		result := gin.H{
+			"webhook_id":     id,
+			"from":           from.UTC().Format(time.RFC3339),
+			"to":             to.UTC().Format(time.RFC3339),
+			"seconds":        seconds,
+			"total_requests": total,
+			"avg_tps":        roundTPS(float64(total)/float64(seconds), precision),
+			"peak_tps":       peak,
+			"peak_at":        nil,
+		}
+		if peak > 0 {
+			result["peak_at"] = peakAt.UTC().Format(time.RFC3339)
+		}
+		c.JSON(http.StatusOK, result)
 	})
 
-	r.DELETE("/api/webhooks/:id", func(c *gin.Context) {
+	// Pause and resume metrics collection, e.g. to leave a warmup out of the stats
+	r.POST("/api/webhooks/:id/pause", func(c *gin.Context) {
 		id := c.Param("id")
-		if webhookServer.deleteWebhook(id) {
-			c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
-		} else {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found or cannot delete default webhook"})
+		webhook, exists := ws.getWebhook(id)
+		if !exists {
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
+			return
 		}
+		webhook.Calculator.Pause()
+		c.JSON(http.StatusOK, gin.H{"message": "Metrics paused", "paused": true})
 	})
 
-	// Metrics endpoints
-	r.GET("/api/webhooks/:id/metrics", func(c *gin.Context) {
+	r.POST("/api/webhooks/:id/resume", func(c *gin.Context) {
 		id := c.Param("id")
-		webhook, exists := webhookServer.getWebhook(id)
+		webhook, exists := ws.getWebhook(id)
 		if !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
 			return
 		}
-		metrics := webhook.Calculator.GetMetrics()
-		c.JSON(http.StatusOK, metrics)
+		webhook.Calculator.Resume()
+		c.JSON(http.StatusOK, gin.H{"message": "Metrics resumed", "paused": false})
 	})
 
 	r.POST("/api/webhooks/:id/reset", func(c *gin.Context) {
 		id := c.Param("id")
-		webhook, exists := webhookServer.getWebhook(id)
+		webhook, exists := ws.getWebhook(id)
 		if !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			respondError(c, http.StatusNotFound, codeWebhookNotFound, "Webhook not found")
 			return
 		}
 		webhook.Calculator.Reset()
@@ -778,27 +8074,34 @@ func main() {
 
 	// Legacy endpoints for backward compatibility
 	r.GET("/api/config", func(c *gin.Context) {
-		webhook, _ := webhookServer.getWebhook("default")
+		webhook, _ := ws.getWebhook("default")
 		c.JSON(http.StatusOK, webhook.Config)
 	})
 
 	r.POST("/api/config", func(c *gin.Context) {
 		var newConfig WebhookConfig
 		if err := c.ShouldBindJSON(&newConfig); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
 			return
 		}
 
-		webhook, _ := webhookServer.getWebhook("default")
-		webhookServer.mu.Lock()
+		ws.mu.Lock()
+		webhook := ws.webhooks["default"]
+		previousConfig := webhook.Config
 		webhook.Config = newConfig
-		webhookServer.mu.Unlock()
+		if err := webhook.compileConfig(ws.dirs); err != nil {
+			webhook.Config = previousConfig
+			ws.mu.Unlock()
+			respondError(c, http.StatusBadRequest, codeInvalidConfig, err.Error())
+			return
+		}
+		ws.mu.Unlock()
 
 		c.JSON(http.StatusOK, gin.H{"message": "Configuration updated"})
 	})
 
 	r.POST("/api/request", func(c *gin.Context) {
-		webhook, _ := webhookServer.getWebhook("default")
+		webhook, _ := ws.getWebhook("default")
 		webhook.Calculator.RecordRequest()
 		c.JSON(http.StatusOK, gin.H{
 			"message":   "Request recorded",
@@ -807,13 +8110,44 @@ func main() {
 	})
 
 	r.GET("/api/metrics", func(c *gin.Context) {
-		webhook, _ := webhookServer.getWebhook("default")
-		metrics := webhook.Calculator.GetMetrics()
+		precision, ok := ws.queryTPSPrecision(c)
+		if !ok {
+			return
+		}
+
+		// Batch fetch: entries follow the order of ?ids=, unknown IDs are marked not_found
+		if rawIDs, batch := c.GetQuery("ids"); batch {
+			entries := make([]gin.H, 0)
+			for _, id := range strings.Split(rawIDs, ",") {
+				id = strings.TrimSpace(id)
+				if id == "" {
+					continue
+				}
+				webhook, exists := ws.getWebhook(id)
+				if !exists {
+					entries = append(entries, gin.H{"id": id, "not_found": true})
+					continue
+				}
+				metrics := webhookMetrics(webhook, ws.percentiles)
+				roundMetricsTPS(metrics, precision)
+				entries = append(entries, gin.H{"id": id, "metrics": metrics})
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"webhooks":  entries,
+				"count":     len(entries),
+				"timestamp": time.Now().Format(time.RFC3339),
+			})
+			return
+		}
+
+		webhook, _ := ws.getWebhook("default")
+		metrics := webhookMetrics(webhook, ws.percentiles)
+		roundMetricsTPS(metrics, precision)
 		c.JSON(http.StatusOK, metrics)
 	})
 
 	r.POST("/api/reset", func(c *gin.Context) {
-		webhook, _ := webhookServer.getWebhook("default")
+		webhook, _ := ws.getWebhook("default")
 		webhook.Calculator.Reset()
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Metrics reset",
@@ -822,40 +8156,363 @@ func main() {
 
 	// Summary endpoint for all webhooks
 	r.GET("/api/summary", func(c *gin.Context) {
-		webhooks := webhookServer.getAllWebhooks()
+		precision, ok := ws.queryTPSPrecision(c)
+		if !ok {
+			return
+		}
+		includeConfig, _ := strconv.ParseBool(c.Query("include_config"))
+		webhooks := ws.getAllWebhooks()
 		summary := make(map[string]interface{})
 		
 		for _, webhook := range webhooks {
-			metrics := webhook.Calculator.GetMetrics()
-			summary[webhook.ID] = map[string]interface{}{
+			metrics := webhook.Calculator.GetMetrics(ws.percentiles)
+			roundMetricsTPS(metrics, precision)
+			entry := map[string]interface{}{
 				"name":            webhook.Name,
 				"path":            webhook.Path,
 				"delay_ms":        webhook.Config.Timeout,
 				"total_requests":  metrics["total_requests"],
 				"tps":             metrics["tps"],
 				"duration_seconds": metrics["duration_seconds"],
+				"age_seconds":     webhook.AgeSeconds(),
 			}
+			if includeConfig {
+				entry["config"] = redactConfig(webhook.Config)
+			}
+			summary[webhook.ID] = entry
 		}
 		
+		if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
+			ids := make([]string, 0, len(summary))
+			for id := range summary {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+
+			var text bytes.Buffer
+			for _, id := range ids {
+				fmt.Fprintf(&text, "== %s ==\n", id)
+				writeMetricsText(&text, summary[id].(map[string]interface{}))
+				text.WriteString("\n")
+			}
+			c.String(http.StatusOK, text.String())
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"summary": summary,
 			"timestamp": time.Now().Format(time.RFC3339),
 		})
 	})
 
+	// Server-wide metrics across all webhooks
+	r.GET("/api/server/metrics", func(c *gin.Context) {
+		precision, ok := ws.queryTPSPrecision(c)
+		if !ok {
+			return
+		}
+		metrics := ws.calculator.GetMetrics(ws.percentiles)
+		roundMetricsTPS(metrics, precision)
+		if detailed, _ := strconv.ParseBool(c.Query("detailed")); detailed {
+			metrics["recent_seconds"] = ws.calculator.RecentSeconds(recentSecondsWindow)
+		}
+		metrics["uptime_seconds"] = time.Since(ws.startedAt).Seconds()
+		metrics["server_start_time"] = ws.startedAt.Format(time.RFC3339)
+		c.JSON(http.StatusOK, metrics)
+	})
+
+	// Server info for operators: uptime, build and totals across all webhooks
+	r.GET("/api/status", func(c *gin.Context) {
+		totalRequests, _ := ws.calculator.Totals()
+		c.JSON(http.StatusOK, gin.H{
+			"start_time":              ws.startedAt.Format(time.RFC3339),
+			"uptime_seconds":          time.Since(ws.startedAt).Seconds(),
+			"webhook_count":           len(ws.getAllWebhooks()),
+			"total_requests":          totalRequests,
+			"buffer_memory_bytes":     ws.bufferMemory.used.Load(),
+			"max_buffer_memory_bytes": ws.bufferMemory.limit,
+			"max_connections":         ws.config.Server.MaxConnections,
+			"go_version":              runtime.Version(),
+			"version":                 version,
+		})
+	})
+
+	// Runtime diagnostics for tracking down goroutine and memory leaks
+	r.GET("/api/debug/stats", func(c *gin.Context) {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		c.JSON(http.StatusOK, gin.H{
+			"goroutines":       runtime.NumGoroutine(),
+			"background_tasks": ws.runningTasks(),
+			"memory": gin.H{
+				"alloc_bytes":       mem.Alloc,
+				"total_alloc_bytes": mem.TotalAlloc,
+				"sys_bytes":         mem.Sys,
+				"heap_inuse_bytes":  mem.HeapInuse,
+				"heap_objects":      mem.HeapObjects,
+				"next_gc_bytes":     mem.NextGC,
+				"num_gc":            mem.NumGC,
+				"gc_pause_total_ms": float64(mem.PauseTotalNs) / float64(time.Millisecond),
+			},
+			"buffer_memory_bytes": ws.bufferMemory.used.Load(),
+		})
+	})
+
+	// Full metrics for every webhook keyed by ID, optionally only those with ?tag=
+	r.GET("/api/metrics/all", func(c *gin.Context) {
+		precision, ok := ws.queryTPSPrecision(c)
+		if !ok {
+			return
+		}
+		tag := c.Query("tag")
+		all := make(map[string]map[string]interface{})
+		for _, webhook := range ws.getAllWebhooks() {
+			if tag != "" && !webhook.hasTag(tag) {
+				continue
+			}
+			metrics := webhookMetrics(webhook, ws.percentiles)
+			roundMetricsTPS(metrics, precision)
+			all[webhook.ID] = metrics
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"webhooks":  all,
+			"count":     len(all),
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	})
+
+	// Aggregate metrics per tag; a webhook with several tags counts towards each
+	r.GET("/api/summary/by-tag", func(c *gin.Context) {
+		precision, ok := ws.queryTPSPrecision(c)
+		if !ok {
+			return
+		}
+		webhooks := ws.getAllWebhooks()
+		sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+
+		type tagSummary struct {
+			WebhookIDs    []string `json:"webhook_ids"`
+			TotalRequests int64    `json:"total_requests"`
+			TPS           float64  `json:"tps"`
+		}
+		summary := make(map[string]*tagSummary)
+
+		for _, webhook := range webhooks {
+			totalRequests, tps := webhook.Calculator.Totals()
+			for _, tag := range webhook.Tags {
+				group, exists := summary[tag]
+				if !exists {
+					group = &tagSummary{WebhookIDs: []string{}}
+					summary[tag] = group
+				}
+				group.WebhookIDs = append(group.WebhookIDs, webhook.ID)
+				group.TotalRequests += totalRequests
+				group.TPS += tps
+			}
+		}
+		for _, group := range summary {
+			group.TPS = roundTPS(group.TPS, precision)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"summary":   summary,
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	})
+
+	// InfluxDB line protocol export, e.g. for a Telegraf exec input
+	r.GET("/api/summary/influx", func(c *gin.Context) {
+		precision, ok := ws.queryTPSPrecision(c)
+		if !ok {
+			return
+		}
+		webhooks := ws.getAllWebhooks()
+		sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+
+		timestamp := time.Now().UnixNano()
+		var builder strings.Builder
+		for _, webhook := range webhooks {
+			totalRequests, tps := webhook.Calculator.Totals()
+			fmt.Fprintf(&builder, "webhook_metrics,id=%s,name=%s tps=%v,total=%di,delay_ms=%di %d\n",
+				escapeInfluxTag(webhook.ID),
+				escapeInfluxTag(webhook.Name),
+				roundTPS(tps, precision),
+				totalRequests,
+				webhook.Config.Timeout,
+				timestamp,
+			)
+		}
+
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(builder.String()))
+	})
+
+	// Snapshot the running configuration as a config.yaml
+	r.GET("/api/config/export", func(c *gin.Context) {
+		var data bytes.Buffer
+		encoder := yaml.NewEncoder(&data)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(ws.exportConfig()); err != nil {
+			respondError(c, http.StatusInternalServerError, codeInternalError, err.Error())
+			return
+		}
+		encoder.Close()
+		c.Header("Content-Disposition", `attachment; filename="config.yaml"`)
+		c.Data(http.StatusOK, "application/x-yaml", data.Bytes())
+	})
+
+	// Apply a config.yaml (YAML or JSON) exported from this or another server
+	r.POST("/api/config/import", func(c *gin.Context) {
+		mode := c.DefaultQuery("mode", "merge")
+		if mode != "merge" && mode != "replace" {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "mode must be merge or replace")
+			return
+		}
+
+		data, err := c.GetRawData()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		// JSON is valid YAML, so one decoder handles both
+		var imported WebhookConfigFile
+		if err := yaml.Unmarshal(data, &imported); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidConfig, "Invalid config: "+err.Error())
+			return
+		}
+
+		changes, err := ws.importConfig(&imported, mode == "replace")
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errorCode(err, codeInvalidConfig), err.Error())
+			return
+		}
+		changes["mode"] = mode
+		c.JSON(http.StatusOK, changes)
+	})
+
+	// Probes for orchestrators: liveness always succeeds, readiness fails
+	// until server.startup_delay has passed
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	r.GET("/readyz", func(c *gin.Context) {
+		if wait := ws.startup.remaining(time.Now()); wait > 0 {
+			c.Header("Retry-After", retryAfterSeconds(wait))
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status":           "starting",
+				"ready_in_seconds": wait.Seconds(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	})
+
+	// Prometheus scrape endpoint
+	r.GET("/metrics", func(c *gin.Context) {
+		precision, ok := ws.queryTPSPrecision(c)
+		if !ok {
+			return
+		}
+		c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(ws.prometheusMetrics(precision)))
+	})
+
+	// Route listing for debugging path collisions
+	r.GET("/api/routes", func(c *gin.Context) {
+		routes := ws.getRoutes()
+		c.JSON(http.StatusOK, gin.H{
+			"routes": routes,
+			"total":  len(routes),
+		})
+	})
+
+	// OpenAPI document for importing the management API into Postman and similar tools
+	openAPISpec := buildOpenAPISpec()
+	r.GET("/api/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, openAPISpec)
+	})
+
 	// Serve static files for web interface
-	r.Static("/static", "./static")
-	r.GET("/", func(c *gin.Context) {
-		c.Redirect(http.StatusMovedPermanently, "/static/index.html")
+	staticFS, err := staticFileSystem(ws.config.Server.StaticSource)
+	if err != nil {
+		return fmt.Errorf("invalid static_source: %w", err)
+	}
+	r.StaticFS("/static", staticFS)
+
+	// Redirect to the web interface when it is deployed, otherwise serve a JSON status page
+	if index, err := staticFS.Open("index.html"); err == nil {
+		index.Close()
+		logrus.Info("Root path redirects to the web interface")
+		r.GET("/", func(c *gin.Context) {
+			c.Redirect(http.StatusMovedPermanently, "/static/index.html")
+		})
+	} else {
+		logrus.Info("Static web interface not found, root path serves a JSON status page")
+		r.GET("/", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{
+				"service":        "tps-calculator webhook server",
+				"version":        version,
+				"webhook_count":  len(ws.getAllWebhooks()),
+				"uptime_seconds": time.Since(ws.startedAt).Seconds(),
+				"api":            "/api/openapi.json",
+			})
+		})
+	}
+
+	return nil
+}
+
+func main() {
+	// Command-line flags take precedence over environment variables and config.yaml
+	configPath := flag.String("config", "config.yaml", "path to the YAML config file")
+	portFlag := flag.Int("port", 0, "listen port (overrides server.port and WEBHOOK_PORT)")
+	hostFlag := flag.String("host", "", "host used in logged URLs (overrides server.host and WEBHOOK_HOST)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Setup logrus for dual output (console + file). The config names the file,
+	// so until it is loaded file output is buffered for applyLoggingConfig.
+	var startupLog bytes.Buffer
+	logrus.SetOutput(io.MultiWriter(os.Stdout, &startupLog))
+
+	// Set log format
+	logrus.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp:   true,
+		TimestampFormat: "2006-01-02 15:04:05",
 	})
 
+	logrus.Info("🎯 Multi-Webhook Server initializing...")
+
+	r := gin.Default()
+	
+	// Add custom panic recovery middleware
+	r.Use(panicRecoveryMiddleware())
+	
+	webhookServer, config := NewWebhookServerWithConfig(r, *configPath)
+	if *portFlag != 0 {
+		if *portFlag < 1 || *portFlag > 65535 {
+			logrus.Fatalf("Invalid -port %d", *portFlag)
+		}
+		config.Server.Port = *portFlag
+	}
+	if *hostFlag != "" {
+		config.Server.Host = *hostFlag
+	}
+	logFile := applyLoggingConfig(config, &startupLog)
+	defer logFile.Close()
+
+	if err := webhookServer.registerRoutes(r); err != nil {
+		logrus.Fatalf("Failed to register routes: %v", err)
+	}
+
 	// Use port from config
 	serverAddr := fmt.Sprintf(":%d", config.Server.Port)
 	baseURL := fmt.Sprintf("http://%s:%d", config.Server.Host, config.Server.Port)
 	
 	logrus.Infof("🎯 Multi-Webhook Server starting on %s", serverAddr)
 	logrus.Infof("📱 Web interface: %s", baseURL)
-	logrus.Info("📋 Log file: webhook.log")
+	logrus.Infof("📋 Log file: %s", logFile.Filename)
 	logrus.Info("")
 	logrus.Info("🔗 Default Webhooks:")
 	logrus.Infof("   • Standard: %s/webhook", baseURL)
@@ -863,6 +8520,64 @@ func main() {
 	logrus.Infof("   • Slow (2s): %s/webhook/slow", baseURL)
 	logrus.Info("")
 	logrus.Infof("🔗 Custom webhooks: %s/w/{webhook-id}", baseURL)
+	if config.Server.CatchAll {
+		logrus.Info("🪤 Catch-all webhook enabled for unmatched paths")
+	}
 	logrus.Infof("📊 API docs: %s/api/webhooks", baseURL)
-	r.Run(serverAddr)
+
+	checkOpenAPISync(r.Routes())
+
+	if config.Server.SelfTest {
+		if failed := webhookServer.runSelfTest(); failed > 0 && config.Server.SelfTestRequired {
+			logrus.Fatalf("Self-test failed for %d webhook(s), aborting startup", failed)
+		}
+	}
+
+	httpServer := &http.Server{
+		Addr:              serverAddr,
+		Handler:           r,
+		ReadTimeout:       config.Server.ReadTimeout,
+		WriteTimeout:      config.Server.WriteTimeout,
+		IdleTimeout:       config.Server.IdleTimeout,
+		ReadHeaderTimeout: config.Server.ReadHeaderTimeout,
+	}
+	// Background loops stop when the process is asked to exit
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go webhookServer.runExpiryJanitor(ctx, expiryCheckInterval)
+	if config.Server.SummaryLogInterval > 0 {
+		go webhookServer.runSummaryLogger(ctx, config.Server.SummaryLogInterval)
+	}
+	if config.Server.StartupDelay > 0 {
+		logrus.Infof("⏳ Not ready for %s (startup_delay)", config.Server.StartupDelay)
+		go webhookServer.announceReady(ctx)
+	}
+
+	listener, err := net.Listen("tcp", serverAddr)
+	if err != nil {
+		logrus.Fatalf("Server failed: %v", err)
+	}
+	if limit := config.Server.MaxConnections; limit > 0 {
+		listener = netutil.LimitListener(listener, limit)
+		logrus.Infof("🔌 Connection limit active: at most %d simultaneous connections (max_connections)", limit)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- httpServer.Serve(listener)
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil && err != http.ErrServerClosed {
+			logrus.Fatalf("Server failed: %v", err)
+		}
+	case <-ctx.Done():
+		logrus.Info("🛑 Shutting down, waiting for in-flight requests...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			logrus.Errorf("Graceful shutdown failed: %v", err)
+		}
+	}
 }
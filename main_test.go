@@ -0,0 +1,613 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestServer builds a server with the default webhooks and every route registered
+func newTestServer(t *testing.T) (*gin.Engine, *WebhookServer) {
+	t.Helper()
+	r := gin.New()
+	ws, _ := NewWebhookServerFromConfig(r, nil)
+	if err := ws.registerRoutes(r); err != nil {
+		t.Fatalf("registerRoutes: %v", err)
+	}
+	return r, ws
+}
+
+func TestAPIRoutesAreInOpenAPISpec(t *testing.T) {
+	r, _ := newTestServer(t)
+	for _, route := range undocumentedRoutes(r.Routes()) {
+		t.Errorf("route %s %s is missing from apiOperations", route.Method, route.Path)
+	}
+}
+
+// doJSON sends a request with a JSON body to r and returns the recorded response
+func doJSON(t *testing.T, r *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// decodeWebhook decodes a webhook from a successful API response
+func decodeWebhook(t *testing.T, w *httptest.ResponseRecorder) Webhook {
+	t.Helper()
+	if w.Code != http.StatusOK && w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var webhook Webhook
+	if err := json.Unmarshal(w.Body.Bytes(), &webhook); err != nil {
+		t.Fatalf("decoding webhook: %v", err)
+	}
+	return webhook
+}
+
+const putTestWebhook = `{"id":"put","name":"put","config":{"status_code":201,"content_type":"text/plain","response_body":"created","timeout":25,"headers":{"X-Test":"1"}}}`
+
+func TestPutMergesOmittedFields(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks", putTestWebhook))
+
+	updated := decodeWebhook(t, doJSON(t, r, http.MethodPut, "/api/webhooks/put", `{"config":{"status_code":202}}`))
+	if updated.Config.StatusCode != 202 {
+		t.Errorf("status_code = %d, want 202", updated.Config.StatusCode)
+	}
+	if updated.Config.ResponseBody != "created" {
+		t.Errorf("response_body = %q, want it kept as %q", updated.Config.ResponseBody, "created")
+	}
+	if updated.Config.ContentType != "text/plain" {
+		t.Errorf("content_type = %q, want it kept as text/plain", updated.Config.ContentType)
+	}
+	if updated.Config.Headers["X-Test"] != "1" {
+		t.Errorf("headers = %v, want X-Test kept", updated.Config.Headers)
+	}
+}
+
+func TestPutReplaceResetsOmittedFields(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks", putTestWebhook))
+
+	updated := decodeWebhook(t, doJSON(t, r, http.MethodPut, "/api/webhooks/put?replace=true", `{"config":{"status_code":202}}`))
+	if updated.Config.StatusCode != 202 {
+		t.Errorf("status_code = %d, want 202", updated.Config.StatusCode)
+	}
+	if want := `{"message": "Request received"}`; updated.Config.ResponseBody != want {
+		t.Errorf("response_body = %q, want default %q", updated.Config.ResponseBody, want)
+	}
+	if updated.Config.ContentType != "application/json" {
+		t.Errorf("content_type = %q, want default application/json", updated.Config.ContentType)
+	}
+	if updated.Config.Timeout != 0 {
+		t.Errorf("timeout = %d, want 0", updated.Config.Timeout)
+	}
+	if len(updated.Config.Headers) != 0 {
+		t.Errorf("headers = %v, want none", updated.Config.Headers)
+	}
+}
+
+func TestCustomPathCountsEachRequestOnce(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks", `{"id":"custom","name":"custom","path":"/hooks/custom"}`))
+
+	// Through /w/:id the client is redirected and follows to the custom path
+	redirect := doJSON(t, r, http.MethodPost, "/w/custom?source=test", `{}`)
+	if redirect.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("/w/custom status = %d, want %d", redirect.Code, http.StatusTemporaryRedirect)
+	}
+	location := redirect.Header().Get("Location")
+	if location != "/hooks/custom?source=test" {
+		t.Fatalf("Location = %q, want /hooks/custom?source=test", location)
+	}
+	if w := doJSON(t, r, http.MethodPost, location, `{}`); w.Code != http.StatusOK {
+		t.Fatalf("following redirect: status %d", w.Code)
+	}
+
+	// Directly on the custom path
+	if w := doJSON(t, r, http.MethodPost, "/hooks/custom", `{}`); w.Code != http.StatusOK {
+		t.Fatalf("custom path: status %d", w.Code)
+	}
+
+	w := doJSON(t, r, http.MethodGet, "/api/webhooks/custom/metrics", "")
+	var metrics struct {
+		TotalRequests int64 `json:"total_requests"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &metrics); err != nil {
+		t.Fatalf("decoding metrics: %v", err)
+	}
+	if metrics.TotalRequests != 2 {
+		t.Errorf("total_requests = %d, want 2 (one per logical request)", metrics.TotalRequests)
+	}
+}
+
+func TestPatchKeepsUnchangedTrackers(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks",
+		`{"id":"seq","name":"seq","config":{"sequence":[{"status_code":201},{"status_code":202}],"backoff":{"initial_delay":1}}}`))
+
+	if w := doJSON(t, r, http.MethodPost, "/w/seq", `{}`); w.Code != 201 {
+		t.Fatalf("first step status = %d, want 201", w.Code)
+	}
+
+	// Clearing backoff leaves the sequence section alone, so its position is kept
+	updated := decodeWebhook(t, doJSON(t, r, http.MethodPatch, "/api/webhooks/seq", `{"config":{"backoff":null}}`))
+	if updated.Config.Backoff != nil {
+		t.Errorf("backoff = %+v, want it cleared by null", updated.Config.Backoff)
+	}
+	if w := doJSON(t, r, http.MethodPost, "/w/seq", `{}`); w.Code != 202 {
+		t.Errorf("second step status = %d, want 202 (sequence restarted by the update)", w.Code)
+	}
+}
+
+func TestUpdatesDoNotRaceWithRequests(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks",
+		`{"id":"live","name":"live","config":{"status_code":201,"headers":{"X-Test":"1"}}}`))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			doJSON(t, r, http.MethodPatch, "/api/webhooks/live", `{"config":{"status_code":202,"headers":{"X-Other":"2"},"content_type":"text/plain"}}`)
+			doJSON(t, r, http.MethodPut, "/api/webhooks/live", `{"config":{"status_code":201,"content_type":"application/json"}}`)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		doJSON(t, r, http.MethodPost, "/w/live", `{}`)
+		doJSON(t, r, http.MethodGet, "/api/webhooks/live", "")
+	}
+	<-done
+}
+
+func TestURITooLongSkipsBodyMetrics(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks",
+		`{"id":"short","name":"short","config":{"max_uri_length":20,"metrics_label":"kind"}}`))
+
+	if w := doJSON(t, r, http.MethodPost, "/w/short?padding="+strings.Repeat("x", 40), `{"kind":"a"}`); w.Code != http.StatusRequestURITooLong {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusRequestURITooLong)
+	}
+
+	var metrics map[string]any
+	if err := json.Unmarshal(doJSON(t, r, http.MethodGet, "/api/webhooks/short/metrics", "").Body.Bytes(), &metrics); err != nil {
+		t.Fatalf("decoding metrics: %v", err)
+	}
+	if metrics["uri_too_long_errors"] != float64(1) {
+		t.Errorf("uri_too_long_errors = %v, want 1", metrics["uri_too_long_errors"])
+	}
+	if metrics["avg_body_bytes"] != nil {
+		t.Errorf("avg_body_bytes = %v, want no body recorded for a 414", metrics["avg_body_bytes"])
+	}
+	if labels, _ := metrics["label_counts"].(map[string]any); len(labels) != 0 {
+		t.Errorf("label_counts = %v, want none for a 414", labels)
+	}
+}
+
+func TestMatchFillsPathParams(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks",
+		`{"id":"orders","name":"orders","path":"/orders/:order_id","config":{"response_body":"order {{.Param \"order_id\"}}","response_template":true}}`))
+
+	w := doJSON(t, r, http.MethodPost, "/api/webhooks/orders/match", `{"path":"/orders/42"}`)
+	var result struct {
+		Body string `json:"body"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decoding match: %v", err)
+	}
+	if result.Body != "order 42" {
+		t.Errorf("body = %q, want %q", result.Body, "order 42")
+	}
+
+	if w := doJSON(t, r, http.MethodPost, "/api/webhooks/orders/match", `{"path":"/invoices/42"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("mismatched path: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestMatchReportsEffectiveDelay(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks",
+		`{"id":"slowish","name":"slowish","config":{"timeout":10,"cold_start":{"idle_ms":60000,"delay_ms":200},"delay_per_kb":1024}}`))
+
+	// The first request is cold and its 1 KiB body adds a second
+	w := doJSON(t, r, http.MethodPost, "/api/webhooks/slowish/match", `{"body":"`+strings.Repeat("x", 1024)+`"}`)
+	var result struct {
+		DelayMs     int64  `json:"delay_ms"`
+		DelaySource string `json:"delay_source"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decoding match: %v", err)
+	}
+	if result.DelayMs != 1224 || result.DelaySource != "cold_start" {
+		t.Errorf("delay = %dms from %q, want 1224ms from cold_start", result.DelayMs, result.DelaySource)
+	}
+}
+
+func TestCircuitBreakerStateMachine(t *testing.T) {
+	breaker, err := newCircuitBreaker(&CircuitBreakerConfig{FailureThreshold: 2, OpenDurationMs: 1000})
+	if err != nil {
+		t.Fatalf("newCircuitBreaker: %v", err)
+	}
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	// Closed: a success resets the count of consecutive failures
+	breaker.record(true, false, at(0))
+	breaker.record(false, false, at(0))
+	if state := breaker.record(true, false, at(0)); state != "" {
+		t.Fatalf("one failure after a success moved the circuit to %q", state)
+	}
+	if state := breaker.record(true, false, at(0)); state != circuitOpen {
+		t.Fatalf("second consecutive failure: state change %q, want %q", state, circuitOpen)
+	}
+
+	// Open: requests are rejected until open_duration_ms is over
+	if allowed, _, retryAfter := breaker.allow(at(400)); allowed || retryAfter != 600*time.Millisecond {
+		t.Errorf("open circuit: allowed=%v retryAfter=%v, want rejected with 600ms", allowed, retryAfter)
+	}
+	if state := breaker.current(at(1000)); state != circuitHalfOpen {
+		t.Errorf("current after open_duration_ms = %q, want %q", state, circuitHalfOpen)
+	}
+
+	// Half-open: one trial at a time; a trial ending without a response frees its slot
+	allowed, trial, _ := breaker.allow(at(1000))
+	if !allowed || !trial {
+		t.Fatalf("half-open: allowed=%v trial=%v, want a trial", allowed, trial)
+	}
+	if allowed, _, _ := breaker.allow(at(1000)); allowed {
+		t.Errorf("second concurrent trial was admitted")
+	}
+	breaker.release(trial)
+	if allowed, trial, _ = breaker.allow(at(1000)); !allowed || !trial {
+		t.Fatalf("after release: allowed=%v trial=%v, want a trial", allowed, trial)
+	}
+
+	// A failed trial reopens the circuit, a successful one closes it
+	if state := breaker.record(true, trial, at(1100)); state != circuitOpen {
+		t.Fatalf("failed trial: state change %q, want %q", state, circuitOpen)
+	}
+	if allowed, _, _ := breaker.allow(at(1500)); allowed {
+		t.Errorf("reopened circuit admitted a request")
+	}
+	if allowed, trial, _ = breaker.allow(at(2100)); !allowed || !trial {
+		t.Fatalf("half-open again: allowed=%v trial=%v, want a trial", allowed, trial)
+	}
+	if state := breaker.record(false, trial, at(2100)); state != circuitClosed {
+		t.Fatalf("successful trial: state change %q, want %q", state, circuitClosed)
+	}
+	if allowed, trial, _ := breaker.allow(at(2100)); !allowed || trial {
+		t.Errorf("closed circuit: allowed=%v trial=%v, want allowed without a trial", allowed, trial)
+	}
+}
+
+func TestMatchReportsOpenCircuit(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks",
+		`{"id":"flaky","name":"flaky","config":{"status_code":500,"circuit_breaker":{"failure_threshold":1,"open_duration_ms":60000}}}`))
+	doJSON(t, r, http.MethodPost, "/w/flaky", `{}`)
+
+	w := doJSON(t, r, http.MethodPost, "/api/webhooks/flaky/match", `{}`)
+	var result struct {
+		Rule       string `json:"rule"`
+		StatusCode int    `json:"status_code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decoding match: %v", err)
+	}
+	if result.Rule != "circuit_breaker" || result.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("match = %+v, want circuit_breaker with 503", result)
+	}
+}
+
+// matchResult decodes the parts of a match response the tests look at
+type matchResult struct {
+	Rule       string            `json:"rule"`
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers"`
+	Body       any               `json:"body"`
+	DelayMs    int64             `json:"delay_ms"`
+}
+
+// doMatch runs a dry-run match against webhook id
+func doMatch(t *testing.T, r *gin.Engine, id, body string) matchResult {
+	t.Helper()
+	w := doJSON(t, r, http.MethodPost, "/api/webhooks/"+id+"/match", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("match status %d: %s", w.Code, w.Body.String())
+	}
+	var result matchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decoding match: %v", err)
+	}
+	return result
+}
+
+func TestMatchPeeksSequenceStep(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks",
+		`{"id":"steps","name":"steps","config":{"sequence":[{"status_code":201,"delay_ms":5},{"status_code":202,"response_body":"second"}]}}`))
+
+	for i := 0; i < 2; i++ {
+		result := doMatch(t, r, "steps", `{}`)
+		if result.Rule != "sequence" || result.StatusCode != 201 || result.DelayMs != 5 || result.Headers["X-Sequence-Step"] != "0" {
+			t.Fatalf("match %d = %+v, want step 0 (201 after 5ms)", i, result)
+		}
+	}
+
+	doJSON(t, r, http.MethodPost, "/w/steps", `{}`)
+	if result := doMatch(t, r, "steps", `{}`); result.StatusCode != 202 || result.Body != "second" {
+		t.Errorf("after one request: match = %+v, want step 1 (202 second)", result)
+	}
+}
+
+func TestMatchPeeksFailFirst(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks",
+		`{"id":"retry","name":"retry","config":{"fail_first_n":1}}`))
+
+	if result := doMatch(t, r, "retry", `{}`); result.Rule != "fail_first_n" || result.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("match = %+v, want fail_first_n with 503", result)
+	}
+	// The match must not use up the failure
+	if w := doJSON(t, r, http.MethodPost, "/w/retry", `{}`); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("first request: status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if result := doMatch(t, r, "retry", `{}`); result.StatusCode != http.StatusOK {
+		t.Errorf("after the failure: match = %+v, want 200", result)
+	}
+}
+
+func TestMatchPicksStickyVariant(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks",
+		`{"id":"ab","name":"ab","config":{"ab_split":{"variants":[{"name":"a","weight":1,"response_body":"A"},{"name":"b","weight":1,"response_body":"B","status_code":202}]}}}`))
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"} {
+		result := doMatch(t, r, "ab", `{"client_ip":"`+ip+`"}`)
+		if result.Rule != "ab_split" {
+			t.Fatalf("rule = %q, want ab_split", result.Rule)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/w/ab", strings.NewReader(`{}`))
+		req.Header.Set("X-Forwarded-For", ip)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if variant := w.Header().Get("X-AB-Variant"); result.Headers["X-AB-Variant"] != variant || result.StatusCode != w.Code || result.Body != w.Body.String() {
+			t.Errorf("%s: match = %+v, request got variant %q (%d %q)", ip, result, variant, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestMatchPeeksResponseBodyList(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks",
+		`{"id":"list","name":"list","config":{"response_body_list":["first",{"body":"<second/>","status_code":202,"content_type":"application/xml"}]}}`))
+
+	if result := doMatch(t, r, "list", `{}`); result.Rule != "response_body_list" || result.Body != "first" || result.StatusCode != http.StatusOK {
+		t.Fatalf("match = %+v, want the first entry", result)
+	}
+	doJSON(t, r, http.MethodPost, "/w/list", `{}`)
+	result := doMatch(t, r, "list", `{}`)
+	if result.Body != "<second/>" || result.StatusCode != 202 || result.Headers["Content-Type"] != "application/xml" {
+		t.Errorf("after one request: match = %+v, want the second entry as 202 application/xml", result)
+	}
+}
+
+func TestPathChangeMovesRoute(t *testing.T) {
+	r, _ := newTestServer(t)
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks", `{"id":"mover","name":"mover","path":"/hooks/old"}`))
+	decodeWebhook(t, doJSON(t, r, http.MethodPost, "/api/webhooks", `{"id":"other","name":"other","path":"/hooks/taken"}`))
+
+	if w := doJSON(t, r, http.MethodPatch, "/api/webhooks/mover", `{"path":"/hooks/taken"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PATCH to another webhook's path: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	decodeWebhook(t, doJSON(t, r, http.MethodPut, "/api/webhooks/mover", `{"path":"/hooks/new"}`))
+	if w := doJSON(t, r, http.MethodPost, "/hooks/new", `{}`); w.Code != http.StatusOK {
+		t.Errorf("new path: status %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := doJSON(t, r, http.MethodPost, "/hooks/old", `{}`); w.Code != http.StatusNotFound {
+		t.Errorf("old path: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := doJSON(t, r, http.MethodPost, "/w/mover", `{}`); w.Header().Get("Location") != "/hooks/new" {
+		t.Errorf("/w/mover redirects to %q, want /hooks/new", w.Header().Get("Location"))
+	}
+}
+
+func TestBodyCaptureDropsWritesAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.log")
+	capture := updateBodyCapture(nil, path, 1)
+	if _, err := capture.Write([]byte("before\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	// Changing the file closes the old writer, which a request may still hold
+	updateBodyCapture(capture, "", 1)
+	if _, err := capture.Write([]byte("after\n")); err != nil {
+		t.Fatalf("write after close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading capture: %v", err)
+	}
+	if string(data) != "before\n" {
+		t.Errorf("capture = %q, want only the write before close", data)
+	}
+}
+
+func TestBulkCreateFansOutWithinBatch(t *testing.T) {
+	r, _ := newTestServer(t)
+	w := doJSON(t, r, http.MethodPost, "/api/webhooks/bulk",
+		`{"atomic":true,"webhooks":[{"id":"front","name":"front","config":{"fan_out_to":["back"]}},{"id":"back","name":"back"}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("atomic batch targeting its own item: status %d: %s", w.Code, w.Body.String())
+	}
+
+	// A target that fails takes the items fanning out to it down too
+	w = doJSON(t, r, http.MethodPost, "/api/webhooks/bulk",
+		`{"webhooks":[{"id":"a","name":"a","config":{"fan_out_to":["b"]}},{"id":"b","name":"b","config":{"fan_out_to":["c"]}},{"id":"c"}]}`)
+	var result struct {
+		Created []Webhook         `json:"created"`
+		Failed  map[string]string `json:"failed"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decoding bulk result: %v", err)
+	}
+	if len(result.Created) != 0 || len(result.Failed) != 3 {
+		t.Errorf("created %d, failed %v; want all three to fail", len(result.Created), result.Failed)
+	}
+}
+
+func TestHyperLogLogEstimate(t *testing.T) {
+	for _, distinct := range []int{0, 1, 10, 1000, 10000, 100000} {
+		var sketch hyperLogLog
+		for i := 0; i < distinct; i++ {
+			ip := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+			// Repeats must not count
+			sketch.add(ip)
+			sketch.add(ip)
+		}
+		estimate := sketch.estimate()
+		// About 3 standard errors of 1.6%, and exact for tiny counts
+		tolerance := int64(math.Ceil(0.05 * float64(distinct)))
+		if diff := estimate - int64(distinct); diff < -tolerance || diff > tolerance {
+			t.Errorf("%d distinct IPs: estimate %d, want within %d", distinct, estimate, tolerance)
+		}
+	}
+}
+
+func TestRecordClientResetAndPause(t *testing.T) {
+	calculator := NewTPSCalculator()
+	uniqueClients := func() int64 {
+		return calculator.GetMetrics(defaultPercentiles)["unique_clients"].(int64)
+	}
+	for i := 0; i < 50; i++ {
+		calculator.RecordClient(fmt.Sprintf("192.168.0.%d", i))
+	}
+	if got := uniqueClients(); got != 50 {
+		t.Errorf("unique_clients = %d, want 50", got)
+	}
+
+	calculator.Reset()
+	if got := uniqueClients(); got != 0 {
+		t.Errorf("after reset: unique_clients = %d, want 0", got)
+	}
+	calculator.RecordClient("192.168.0.1")
+	if got := uniqueClients(); got != 1 {
+		t.Errorf("after reset and one client: unique_clients = %d, want 1", got)
+	}
+
+	calculator.Pause()
+	calculator.RecordClient("192.168.0.2")
+	calculator.Resume()
+	if got := uniqueClients(); got != 1 {
+		t.Errorf("client recorded while paused: unique_clients = %d, want 1", got)
+	}
+}
+
+// The vector is GitHub's documented webhook signature example
+const (
+	hmacTestSecret = "It's a Secret to Everybody"
+	hmacTestBody   = "Hello, World!"
+)
+
+func TestSignatureSign(t *testing.T) {
+	tests := []struct {
+		name   string
+		config SignatureVerification
+		want   string
+	}{
+		{"sha256 hex with prefix", SignatureVerification{Secret: hmacTestSecret, Prefix: "sha256="},
+			"sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"},
+		{"sha1 base64", SignatureVerification{Secret: hmacTestSecret, Algorithm: "SHA1", Encoding: "base64"},
+			"AdwQ0Mg+cu0kYhnN2RZpZn/iylk="},
+		{"sha512 hex", SignatureVerification{Secret: hmacTestSecret, Algorithm: "sha512"},
+			"11ed355a617e98134e842012a7944ccf59c10256cb182357bd7e3a42013ff07c376f8c14cf5cc1923da20b51d64256b2fb8ebbf100aa67a61326f61fea8111bc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.config.withDefaults("signature_verification", "X-Signature")
+			if err != nil {
+				t.Fatalf("withDefaults: %v", err)
+			}
+			if got := config.sign([]byte(hmacTestBody)); got != tt.want {
+				t.Errorf("sign = %q, want %q", got, tt.want)
+			}
+			if reason := config.verify(tt.want, []byte(hmacTestBody)); reason != "" {
+				t.Errorf("verify of its own signature: %s", reason)
+			}
+		})
+	}
+}
+
+func TestSignatureVerify(t *testing.T) {
+	config, err := SignatureVerification{Secret: hmacTestSecret, Prefix: "sha256="}.withDefaults("signature_verification", "X-Signature")
+	if err != nil {
+		t.Fatalf("withDefaults: %v", err)
+	}
+	valid := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	tests := []struct {
+		name   string
+		header string
+		body   string
+		want   string
+	}{
+		{"valid", valid, hmacTestBody, ""},
+		{"missing", "", hmacTestBody, "missing X-Signature header"},
+		{"not hex", "sha256=zz", hmacTestBody, "malformed signature"},
+		{"other body", valid, "Hello, World?", "signature mismatch"},
+		{"truncated", valid[:len(valid)-2], hmacTestBody, "signature mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := config.verify(tt.header, []byte(tt.body)); got != tt.want {
+				t.Errorf("verify = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignatureWithDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  SignatureVerification
+		wantErr bool
+	}{
+		{"defaults", SignatureVerification{Secret: "s"}, false},
+		{"no secret", SignatureVerification{}, true},
+		{"unknown algorithm", SignatureVerification{Secret: "s", Algorithm: "md5"}, true},
+		{"unknown encoding", SignatureVerification{Secret: "s", Encoding: "base32"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.config.withDefaults("response_signing", "X-Response-Signature")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (config.Header != "X-Response-Signature" || config.Algorithm != "sha256" || config.Encoding != "hex") {
+				t.Errorf("defaults = %+v, want X-Response-Signature, sha256, hex", config)
+			}
+		})
+	}
+}